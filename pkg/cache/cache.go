@@ -99,3 +99,12 @@ func raise(event Events, key string, value any) {
 		value(event, value)
 	}
 }
+
+func Keys() []string {
+	keys := make([]string, 0)
+	_store.Range(func(key, value any) bool {
+		keys = append(keys, key.(string))
+		return true
+	})
+	return keys
+}
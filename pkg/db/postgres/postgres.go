@@ -86,6 +86,10 @@ func (pool *Pool) Query(ctx context.Context, sql string, arguments map[string]an
 	return rows, nil
 }
 
+func (pool *Pool) Ping(ctx context.Context) error {
+	return pool.pool.Ping(ctx)
+}
+
 func (pool *Pool) Close() {
 	pool.cancelFunc()
 	pool.pool.Close()
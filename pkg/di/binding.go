@@ -0,0 +1,127 @@
+package di
+
+import (
+	"reflect"
+)
+
+func (r Registry[T]) BindWithName(name string) error {
+	contract := nameOf[T]()
+	if reflect.TypeOf((*T)(nil)).Elem().Kind() != reflect.Interface {
+		return invalidContractError(contract)
+	}
+	r.container.bindMute.Lock()
+	defer r.container.bindMute.Unlock()
+	value, ok := r.container.bindings.Load(contract)
+	if !ok {
+		value = make([]string, 0)
+	}
+	bindings := value.([]string)
+	for _, binding := range bindings {
+		if binding == name {
+			return bindingAlreadyExistsError(contract, name)
+		}
+	}
+	r.container.bindings.Store(contract, append(bindings, name))
+	return nil
+}
+
+func (r Registry[T]) Bindings() []string {
+	contract := nameOf[T]()
+	return r.container.bindingsOf(contract)
+}
+
+func (r Registry[T]) ResolveAll(options *options) ([]T, error) {
+	bindings := r.Bindings()
+	out := make([]T, 0, len(bindings))
+	for _, name := range bindings {
+		value, err := r.resolveBinding(name, options)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, value)
+	}
+	return out, nil
+}
+
+func (r Registry[T]) ResolveBound(options *options) (T, error) {
+	bindings := r.Bindings()
+	if len(bindings) == 0 {
+		return *new(T), objectNotFoundError(nameOf[T]())
+	}
+	return r.resolveBinding(bindings[len(bindings)-1], options)
+}
+
+func (r Registry[T]) ResolveBoundWithName(name string, options *options) (T, error) {
+	for _, binding := range r.Bindings() {
+		if binding == name {
+			return r.resolveBinding(name, options)
+		}
+	}
+	return *new(T), bindingNotFoundError(nameOf[T](), name)
+}
+
+func (r Registry[T]) resolveBinding(name string, options *options) (T, error) {
+	instance, err := r.container.resolveAny(name, options)
+	if err != nil {
+		return *new(T), err
+	}
+	value, ok := instance.(T)
+	if !ok {
+		return *new(T), invalidCastError(nameOf[T]())
+	}
+	return value, nil
+}
+
+func (c *Container) bindingsOf(contract string) []string {
+	containers := make([]*Container, 0)
+	for container := c; container != nil; container = container.parent {
+		containers = append(containers, container)
+	}
+	out := make([]string, 0)
+	for i := len(containers) - 1; i >= 0; i-- {
+		value, ok := containers[i].bindings.Load(contract)
+		if ok {
+			out = append(out, value.([]string)...)
+		}
+	}
+	return out
+}
+
+func BindIn[TInterface any, TImplementation any](container *Container) error {
+	return BindInWithName[TInterface, TImplementation](container, nameOf[TImplementation]())
+}
+
+func BindInWithName[TInterface any, TImplementation any](container *Container, name string) error {
+	contract := reflect.TypeOf((*TInterface)(nil)).Elem()
+	if contract.Kind() != reflect.Interface {
+		return invalidContractError(contract.String())
+	}
+	if !reflect.TypeOf((*TImplementation)(nil)).Implements(contract) {
+		return notImplementedError(nameOf[TImplementation](), contract.String())
+	}
+	return For[TInterface](container).BindWithName(name)
+}
+
+func Bind[TInterface any, TImplementation any]() error {
+	return BindIn[TInterface, TImplementation](_default)
+}
+
+func BindWithName[TInterface any, TImplementation any](name string) error {
+	return BindInWithName[TInterface, TImplementation](_default, name)
+}
+
+func Bindings[T any]() []string {
+	return For[T](_default).Bindings()
+}
+
+func ResolveAll[T any](options *options) ([]T, error) {
+	return For[T](_default).ResolveAll(options)
+}
+
+func ResolveBound[T any](options *options) (T, error) {
+	return For[T](_default).ResolveBound(options)
+}
+
+func ResolveBoundWithName[T any](name string, options *options) (T, error) {
+	return For[T](_default).ResolveBoundWithName(name, options)
+}
@@ -0,0 +1,157 @@
+package di
+
+import (
+	"context"
+	"reflect"
+)
+
+var (
+	_errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	_contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+)
+
+func (r Registry[T]) AddSinletonConstructor(constructor any, dependencies ...string) error {
+	name := nameOf[T]()
+	return r.AddSinletonConstructorWithName(name, constructor, dependencies...)
+}
+
+func (r Registry[T]) AddSinletonConstructorWithName(name string, constructor any, dependencies ...string) error {
+	service, deps, err := constructorOf[T](r.container, name, constructor, dependencies)
+	if err != nil {
+		return err
+	}
+	err = r.addSinleton(name, service)
+	if err != nil {
+		return err
+	}
+	r.container.dependencies.Store(name, deps)
+	return nil
+}
+
+func (r Registry[T]) AddTransientConstructor(constructor any, dependencies ...string) error {
+	name := nameOf[T]()
+	return r.AddTransientConstructorWithName(name, constructor, dependencies...)
+}
+
+func (r Registry[T]) AddTransientConstructorWithName(name string, constructor any, dependencies ...string) error {
+	service, deps, err := constructorOf[T](r.container, name, constructor, dependencies)
+	if err != nil {
+		return err
+	}
+	err = r.addTransient(name, service)
+	if err != nil {
+		return err
+	}
+	r.container.dependencies.Store(name, deps)
+	return nil
+}
+
+func (r Registry[T]) AddScopedConstructor(constructor any, dependencies ...string) error {
+	name := nameOf[T]()
+	return r.AddScopedConstructorWithName(name, constructor, dependencies...)
+}
+
+func (r Registry[T]) AddScopedConstructorWithName(name string, constructor any, dependencies ...string) error {
+	service, deps, err := constructorOf[T](r.container, name, constructor, dependencies)
+	if err != nil {
+		return err
+	}
+	err = r.addScoped(name, service)
+	if err != nil {
+		return err
+	}
+	r.container.dependencies.Store(name, deps)
+	return nil
+}
+
+func AddSinletonConstructor[T any](constructor any, dependencies ...string) error {
+	return For[T](_default).AddSinletonConstructor(constructor, dependencies...)
+}
+
+func AddSinletonConstructorWithName[T any](name string, constructor any, dependencies ...string) error {
+	return For[T](_default).AddSinletonConstructorWithName(name, constructor, dependencies...)
+}
+
+func AddTransientConstructor[T any](constructor any, dependencies ...string) error {
+	return For[T](_default).AddTransientConstructor(constructor, dependencies...)
+}
+
+func AddTransientConstructorWithName[T any](name string, constructor any, dependencies ...string) error {
+	return For[T](_default).AddTransientConstructorWithName(name, constructor, dependencies...)
+}
+
+func AddScopedConstructor[T any](constructor any, dependencies ...string) error {
+	return For[T](_default).AddScopedConstructor(constructor, dependencies...)
+}
+
+func AddScopedConstructorWithName[T any](name string, constructor any, dependencies ...string) error {
+	return For[T](_default).AddScopedConstructorWithName(name, constructor, dependencies...)
+}
+
+// constructorOf turns a function such as func(*nats.Conn, *postgres.Pool) (*T, error)
+// into a factory that resolves every parameter from the container. A non-empty entry
+// in dependencies overrides the registration name used for the parameter at the same
+// position; otherwise the name is derived from the parameter type. Interface parameters
+// resolve to the most recent binding of the interface, and a leading context.Context
+// parameter receives the context of the resolution.
+func constructorOf[T any](container *Container, name string, constructor any, dependencies []string) (factory[T], []string, error) {
+	if constructor == nil {
+		return nil, nil, invalidConstructorError(name)
+	}
+	value := reflect.ValueOf(constructor)
+	typeOf := value.Type()
+	if typeOf.Kind() != reflect.Func || typeOf.IsVariadic() || typeOf.NumOut() != 2 {
+		return nil, nil, invalidConstructorError(name)
+	}
+	if typeOf.Out(0) != reflect.TypeOf((*T)(nil)) || typeOf.Out(1) != _errorType {
+		return nil, nil, invalidConstructorError(name)
+	}
+	offset := 0
+	if typeOf.NumIn() != 0 && typeOf.In(0) == _contextType {
+		offset = 1
+	}
+	if len(dependencies) > typeOf.NumIn()-offset {
+		return nil, nil, tooManyDependenciesError(name)
+	}
+	deps := make([]string, typeOf.NumIn()-offset)
+	for i := range deps {
+		if i < len(dependencies) && dependencies[i] != "" {
+			deps[i] = dependencies[i]
+			continue
+		}
+		in := typeOf.In(i + offset)
+		switch in.Kind() {
+		case reflect.Pointer:
+			deps[i] = in.Elem().String()
+		case reflect.Interface:
+			deps[i] = in.String()
+		default:
+			return nil, nil, invalidDependencyError(name, i+offset)
+		}
+	}
+	service := func(options *options) (instance *T, err error) {
+		if cycle := container.findCycle(name); cycle != nil {
+			return nil, circularDependencyError(cycle)
+		}
+		args := make([]reflect.Value, typeOf.NumIn())
+		if offset == 1 {
+			args[0] = reflect.ValueOf(options.context())
+		}
+		for i, dep := range deps {
+			arg, err := container.resolveAny(dep, options)
+			if err != nil {
+				return nil, err
+			}
+			argValue := reflect.ValueOf(arg)
+			if arg == nil || !argValue.Type().AssignableTo(typeOf.In(i+offset)) {
+				return nil, invalidCastError(typeOf.In(i + offset).String())
+			}
+			args[i+offset] = argValue
+		}
+		out := value.Call(args)
+		instance, _ = out[0].Interface().(*T)
+		err, _ = out[1].Interface().(error)
+		return instance, err
+	}
+	return service, deps, nil
+}
@@ -0,0 +1,121 @@
+package di
+
+import (
+	"sync"
+)
+
+type Container struct {
+	parent        *Container
+	refresh       sync.Map
+	typedRefresh  sync.Map
+	versions      sync.Map
+	metadata      sync.Map
+	contextTypes  sync.Map
+	context       sync.Map
+	scopedContext sync.Map
+	scopes        sync.Map
+	resolvers     sync.Map
+	dependencies  sync.Map
+	bindings      sync.Map
+	decorators    sync.Map
+	refreshMute   sync.Mutex
+	eventMute     sync.Mutex
+	bindMute      sync.Mutex
+	decorateMute  sync.Mutex
+	interceptors  []Interceptor
+	backoffMute   sync.Mutex
+	backoff       *Backoff
+	disposer      disposer
+}
+
+var (
+	_default *Container
+)
+
+func init() {
+	_default = NewContainer()
+}
+
+func NewContainer() *Container {
+	return &Container{}
+}
+
+func Default() *Container {
+	return _default
+}
+
+func (c *Container) NewChild() *Container {
+	child := NewContainer()
+	child.parent = c
+	return child
+}
+
+func (c *Container) Parent() *Container {
+	return c.parent
+}
+
+func (c *Container) HasWithName(name string) bool {
+	_, _, _, ok := c.lookup(name)
+	return ok
+}
+
+func (c *Container) OnRefreshWithName(name string, cb func(Events)) {
+	c.eventMute.Lock()
+	defer c.eventMute.Unlock()
+	value, ok := c.refresh.Load(name)
+	if !ok {
+		value = make([]func(Events), 0)
+	}
+	value = append(value.([]func(Events)), cb)
+	c.refresh.Store(name, value)
+}
+
+func (c *Container) CloseScope(option options) error {
+	if option.scope != nil {
+		return option.scope.Close()
+	}
+	value, ok := c.scopedContext.LoadAndDelete(option.scopeId)
+	if !ok {
+		return nil
+	}
+	return value.(*Scope).Close()
+}
+
+func (c *Container) lookup(name string) (*Container, LifeCycles, any, bool) {
+	for container := c; container != nil; container = container.parent {
+		lifeCycle, ok := container.contextTypes.Load(name)
+		if !ok {
+			continue
+		}
+		object, ok := container.context.Load(name)
+		if !ok {
+			continue
+		}
+		return container, lifeCycle.(LifeCycles), object, true
+	}
+	return nil, 0, nil, false
+}
+
+func (c *Container) raise(name string, event Events) {
+	values, ok := c.refresh.Load(name)
+	if !ok {
+		return
+	}
+	for _, value := range values.([]func(Events)) {
+		value(event)
+	}
+}
+
+func (c *Container) resolveAny(name string, opt *options) (any, error) {
+	for container := c; container != nil; container = container.parent {
+		resolver, ok := container.resolvers.Load(name)
+		if ok {
+			return resolver.(func(*options) (any, error))(opt)
+		}
+	}
+	bindings := c.bindingsOf(name)
+	if len(bindings) != 0 {
+		return c.resolveAny(bindings[len(bindings)-1], opt)
+	}
+	return nil, objectNotFoundError(name)
+}
@@ -0,0 +1,86 @@
+package di
+
+import (
+	"context"
+)
+
+func contextFactoryOf[T any](service func(ctx context.Context) (instance *T, err error)) factory[T] {
+	return func(options *options) (instance *T, err error) {
+		return service(options.context())
+	}
+}
+
+func (r Registry[T]) AddSinletonWithContext(service func(ctx context.Context) (instance *T, err error)) error {
+	name := nameOf[T]()
+	return r.AddSinletonWithContextAndName(name, service)
+}
+
+func (r Registry[T]) AddSinletonWithContextAndName(name string, service func(ctx context.Context) (instance *T, err error)) error {
+	return r.addSinleton(name, contextFactoryOf(service))
+}
+
+func (r Registry[T]) AddTransientWithContext(service func(ctx context.Context) (instance *T, err error)) error {
+	name := nameOf[T]()
+	return r.AddTransientWithContextAndName(name, service)
+}
+
+func (r Registry[T]) AddTransientWithContextAndName(name string, service func(ctx context.Context) (instance *T, err error)) error {
+	return r.addTransient(name, contextFactoryOf(service))
+}
+
+func (r Registry[T]) AddScopedWithContext(service func(ctx context.Context) (instance *T, err error)) error {
+	name := nameOf[T]()
+	return r.AddScopedWithContextAndName(name, service)
+}
+
+func (r Registry[T]) AddScopedWithContextAndName(name string, service func(ctx context.Context) (instance *T, err error)) error {
+	return r.addScoped(name, contextFactoryOf(service))
+}
+
+func (r Registry[T]) ResolveWithContext(ctx context.Context, options *options) (instance *T, err error) {
+	name := nameOf[T]()
+	return r.ResolveWithNameAndContext(ctx, name, options)
+}
+
+func (r Registry[T]) ResolveWithNameAndContext(ctx context.Context, name string, options *options) (instance *T, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return r.ResolveWithName(name, options.withContext(ctx))
+}
+
+func (l *Lazy[T]) GetWithContext(ctx context.Context) (*T, error) {
+	return l.get(l.options.withContext(ctx))
+}
+
+func AddSinletonWithContext[T any](service func(ctx context.Context) (instance *T, err error)) error {
+	return For[T](_default).AddSinletonWithContext(service)
+}
+
+func AddSinletonWithContextAndName[T any](name string, service func(ctx context.Context) (instance *T, err error)) error {
+	return For[T](_default).AddSinletonWithContextAndName(name, service)
+}
+
+func AddTransientWithContext[T any](service func(ctx context.Context) (instance *T, err error)) error {
+	return For[T](_default).AddTransientWithContext(service)
+}
+
+func AddTransientWithContextAndName[T any](name string, service func(ctx context.Context) (instance *T, err error)) error {
+	return For[T](_default).AddTransientWithContextAndName(name, service)
+}
+
+func AddScopedWithContext[T any](service func(ctx context.Context) (instance *T, err error)) error {
+	return For[T](_default).AddScopedWithContext(service)
+}
+
+func AddScopedWithContextAndName[T any](name string, service func(ctx context.Context) (instance *T, err error)) error {
+	return For[T](_default).AddScopedWithContextAndName(name, service)
+}
+
+func ResolveWithContext[T any](ctx context.Context, options *options) (instance *T, err error) {
+	return For[T](_default).ResolveWithContext(ctx, options)
+}
+
+func ResolveWithNameAndContext[T any](ctx context.Context, name string, options *options) (instance *T, err error) {
+	return For[T](_default).ResolveWithNameAndContext(ctx, name, options)
+}
@@ -0,0 +1,116 @@
+package di
+
+type Resolution struct {
+	Name      string
+	Type      string
+	LifeCycle LifeCycles
+	Scope     *Scope
+}
+
+type Interceptor func(resolution Resolution, next func() (any, error)) (any, error)
+
+func (r Registry[T]) Decorate(decorator func(inner *T) (instance *T, err error)) error {
+	name := nameOf[T]()
+	return r.DecorateWithName(name, decorator)
+}
+
+// DecorateWithName registers the decorator on the container that owns the registration
+// and applies it to the singleton right away when it has already been created.
+func (r Registry[T]) DecorateWithName(name string, decorator func(inner *T) (instance *T, err error)) error {
+	owner, lifeCycle, object, ok := r.container.lookup(name)
+	if !ok {
+		return objectNotFoundError(name)
+	}
+	register := func() {
+		owner.decorateMute.Lock()
+		defer owner.decorateMute.Unlock()
+		value, ok := owner.decorators.Load(name)
+		if !ok {
+			value = make([]any, 0)
+		}
+		owner.decorators.Store(name, append(value.([]any), decorator))
+	}
+	if lifeCycle != SINGLETON {
+		register()
+		return nil
+	}
+	singleton, ok := object.(*singleton[T])
+	if !ok {
+		return invalidCastError(name)
+	}
+	applied, err := singleton.decorate(decorator, register)
+	if err != nil {
+		return err
+	}
+	if applied {
+		owner.bump(name)
+	}
+	return nil
+}
+
+func (c *Container) Intercept(interceptor Interceptor) {
+	c.decorateMute.Lock()
+	defer c.decorateMute.Unlock()
+	interceptors := make([]Interceptor, 0, len(c.interceptors)+1)
+	interceptors = append(interceptors, c.interceptors...)
+	c.interceptors = append(interceptors, interceptor)
+}
+
+func (c *Container) intercept(resolution Resolution, resolve func() (any, error)) (any, error) {
+	chain := make([]Interceptor, 0)
+	for container := c; container != nil; container = container.parent {
+		container.decorateMute.Lock()
+		interceptors := container.interceptors
+		container.decorateMute.Unlock()
+		merged := make([]Interceptor, 0, len(interceptors)+len(chain))
+		merged = append(merged, interceptors...)
+		chain = append(merged, chain...)
+	}
+	if len(chain) == 0 {
+		return resolve()
+	}
+	next := resolve
+	for i := len(chain) - 1; i >= 0; i-- {
+		interceptor, inner := chain[i], next
+		next = func() (any, error) {
+			return interceptor(resolution, inner)
+		}
+	}
+	return next()
+}
+
+func decorated[T any](container *Container, name string, service factory[T]) factory[T] {
+	return func(options *options) (instance *T, err error) {
+		instance, err = service(options)
+		if err != nil {
+			return nil, err
+		}
+		value, ok := container.decorators.Load(name)
+		if !ok {
+			return instance, nil
+		}
+		for _, value := range value.([]any) {
+			decorator, ok := value.(func(inner *T) (instance *T, err error))
+			if !ok {
+				return nil, invalidCastError(name)
+			}
+			instance, err = decorator(instance)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return instance, nil
+	}
+}
+
+func Decorate[T any](decorator func(inner *T) (instance *T, err error)) error {
+	return For[T](_default).Decorate(decorator)
+}
+
+func DecorateWithName[T any](name string, decorator func(inner *T) (instance *T, err error)) error {
+	return For[T](_default).DecorateWithName(name, decorator)
+}
+
+func Intercept(interceptor Interceptor) {
+	_default.Intercept(interceptor)
+}
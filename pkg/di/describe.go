@@ -0,0 +1,137 @@
+package di
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type metadata struct {
+	mute      sync.Mutex
+	typeName  string
+	createdAt time.Time
+	lastError error
+}
+
+type Descriptor struct {
+	Name         string     `json:"name"`
+	Type         string     `json:"type"`
+	LifeCycle    LifeCycles `json:"lifeCycle"`
+	Created      bool       `json:"created"`
+	CreatedAt    *time.Time `json:"createdAt,omitempty"`
+	LastError    string     `json:"lastError,omitempty"`
+	RefreshCount uint64     `json:"refreshCount"`
+	Dependencies []string   `json:"dependencies"`
+	Contracts    []string   `json:"contracts"`
+}
+
+func (l LifeCycles) String() string {
+	switch l {
+	case SINGLETON:
+		return "SINGLETON"
+	case TRANSIENT:
+		return "TRANSIENT"
+	case SCOPED:
+		return "SCOPED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+func (l LifeCycles) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+func (c *Container) Describe() []Descriptor {
+	descriptors := make([]Descriptor, 0)
+	seen := make(map[string]bool)
+	contracts := c.contractsOf()
+	for container := c; container != nil; container = container.parent {
+		container.contextTypes.Range(func(key, value any) bool {
+			name := key.(string)
+			if seen[name] {
+				return true
+			}
+			seen[name] = true
+			descriptor := Descriptor{
+				Name:         name,
+				LifeCycle:    value.(LifeCycles),
+				Dependencies: container.DependenciesOfName(name),
+				Contracts:    contracts[name],
+			}
+			if descriptor.Contracts == nil {
+				descriptor.Contracts = []string{}
+			}
+			if version, ok := container.versions.Load(name); ok {
+				descriptor.RefreshCount = version.(*atomic.Uint64).Load()
+			}
+			if value, ok := container.metadata.Load(name); ok {
+				metadata := value.(*metadata)
+				metadata.mute.Lock()
+				descriptor.Type = metadata.typeName
+				if !metadata.createdAt.IsZero() {
+					createdAt := metadata.createdAt
+					descriptor.Created = true
+					descriptor.CreatedAt = &createdAt
+				}
+				if metadata.lastError != nil {
+					descriptor.LastError = metadata.lastError.Error()
+				}
+				metadata.mute.Unlock()
+			}
+			descriptors = append(descriptors, descriptor)
+			return true
+		})
+	}
+	sort.Slice(descriptors, func(i, j int) bool {
+		return descriptors[i].Name < descriptors[j].Name
+	})
+	return descriptors
+}
+
+func Describe() []Descriptor {
+	return _default.Describe()
+}
+
+func (c *Container) contractsOf() map[string][]string {
+	contracts := make(map[string][]string)
+	for _, contract := range c.contracts() {
+		for _, name := range c.bindingsOf(contract) {
+			contracts[name] = append(contracts[name], contract)
+		}
+	}
+	return contracts
+}
+
+func (c *Container) describe(name string, typeName string, replace bool) {
+	if replace {
+		c.metadata.Store(name, &metadata{typeName: typeName})
+		return
+	}
+	c.metadata.LoadOrStore(name, &metadata{typeName: typeName})
+}
+
+func (c *Container) record(name string, err error) {
+	value, ok := c.metadata.Load(name)
+	if !ok {
+		return
+	}
+	metadata := value.(*metadata)
+	metadata.mute.Lock()
+	defer metadata.mute.Unlock()
+	if err != nil {
+		metadata.lastError = err
+		return
+	}
+	metadata.createdAt = time.Now()
+}
+
+func recorded[T any](container *Container, name string, service factory[T]) factory[T] {
+	return func(options *options) (instance *T, err error) {
+		instance, err = service(options)
+		container.record(name, err)
+		return instance, err
+	}
+}
@@ -0,0 +1,157 @@
+package di
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+type drainer interface {
+	Drain() error
+}
+
+type closer interface {
+	Close()
+}
+
+type closedChecker interface {
+	IsClosed() bool
+}
+
+type drainingChecker interface {
+	IsDraining() bool
+}
+
+type disposable struct {
+	name     string
+	instance any
+	dispose  func() error
+}
+
+type disposer struct {
+	mute        sync.Mutex
+	disposables []*disposable
+}
+
+func disposerOf(instance any) func() error {
+	dispose := disposeFuncOf(instance)
+	if dispose == nil {
+		return nil
+	}
+	return func() error {
+		if disposed(instance) {
+			return nil
+		}
+		return dispose()
+	}
+}
+
+func disposeFuncOf(instance any) func() error {
+	switch value := instance.(type) {
+	case drainer:
+		return value.Drain
+	case io.Closer:
+		return value.Close
+	case closer:
+		return func() error {
+			value.Close()
+			return nil
+		}
+	default:
+		return nil
+	}
+}
+
+func disposed(instance any) bool {
+	if value, ok := instance.(closedChecker); ok && value.IsClosed() {
+		return true
+	}
+	if value, ok := instance.(drainingChecker); ok && value.IsDraining() {
+		return true
+	}
+	return false
+}
+
+func (d *disposer) track(name string, instance any) {
+	dispose := disposerOf(instance)
+	if dispose == nil {
+		return
+	}
+	d.mute.Lock()
+	defer d.mute.Unlock()
+	d.disposables = append(d.disposables, &disposable{
+		name:     name,
+		instance: instance,
+		dispose:  dispose,
+	})
+}
+
+func (d *disposer) untrack(instance any) *disposable {
+	d.mute.Lock()
+	defer d.mute.Unlock()
+	for i, value := range d.disposables {
+		if value.instance == instance {
+			d.disposables = append(d.disposables[:i], d.disposables[i+1:]...)
+			return value
+		}
+	}
+	return nil
+}
+
+func (d *disposer) dispose(instance any) error {
+	disposable := d.untrack(instance)
+	if disposable == nil {
+		return nil
+	}
+	err := disposable.dispose()
+	if err != nil {
+		return disposeError(disposable.name, err)
+	}
+	return nil
+}
+
+func (d *disposer) disposeAll(ctx context.Context) error {
+	d.mute.Lock()
+	disposables := d.disposables
+	d.disposables = nil
+	d.mute.Unlock()
+	errs := make([]error, 0)
+	for i := len(disposables) - 1; i >= 0; i-- {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+		err := disposables[i].dispose()
+		if err != nil {
+			errs = append(errs, disposeError(disposables[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Container) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		errs := make([]error, 0)
+		c.scopes.Range(func(key, value any) bool {
+			err := value.(*Scope).close(ctx)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			return true
+		})
+		errs = append(errs, c.disposer.disposeAll(ctx))
+		done <- errors.Join(errs...)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func Shutdown(ctx context.Context) error {
+	return _default.Shutdown(ctx)
+}
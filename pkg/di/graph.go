@@ -0,0 +1,106 @@
+package di
+
+import (
+	"sort"
+)
+
+type Node struct {
+	Name         string
+	LifeCycle    LifeCycles
+	Dependencies []string
+}
+
+func (c *Container) Graph() []Node {
+	nodes := make([]Node, 0)
+	seen := make(map[string]bool)
+	for container := c; container != nil; container = container.parent {
+		container.contextTypes.Range(func(key, value any) bool {
+			name := key.(string)
+			if seen[name] {
+				return true
+			}
+			seen[name] = true
+			nodes = append(nodes, Node{
+				Name:         name,
+				LifeCycle:    value.(LifeCycles),
+				Dependencies: c.DependenciesOfName(name),
+			})
+			return true
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+func (c *Container) DependenciesOfName(name string) []string {
+	for container := c; container != nil; container = container.parent {
+		if _, ok := container.contextTypes.Load(name); !ok {
+			continue
+		}
+		value, ok := container.dependencies.Load(name)
+		if !ok {
+			return []string{}
+		}
+		dependencies := value.([]string)
+		out := make([]string, len(dependencies))
+		copy(out, dependencies)
+		return out
+	}
+	bindings := c.bindingsOf(name)
+	if len(bindings) != 0 {
+		return bindings[len(bindings)-1:]
+	}
+	return []string{}
+}
+
+func (r Registry[T]) DependenciesOf() []string {
+	name := nameOf[T]()
+	return r.container.DependenciesOfName(name)
+}
+
+func Graph() []Node {
+	return _default.Graph()
+}
+
+func DependenciesOf[T any]() []string {
+	return For[T](_default).DependenciesOf()
+}
+
+func DependenciesOfName(name string) []string {
+	return _default.DependenciesOfName(name)
+}
+
+func (c *Container) findCycle(name string) []string {
+	path := make([]string, 0)
+	onPath := make(map[string]bool)
+	visited := make(map[string]bool)
+	var visit func(node string) []string
+	visit = func(node string) []string {
+		if onPath[node] {
+			for i, value := range path {
+				if value == node {
+					cycle := make([]string, 0, len(path)-i+1)
+					cycle = append(cycle, path[i:]...)
+					return append(cycle, node)
+				}
+			}
+		}
+		if visited[node] {
+			return nil
+		}
+		onPath[node] = true
+		path = append(path, node)
+		for _, dependency := range c.DependenciesOfName(node) {
+			if cycle := visit(dependency); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		onPath[node] = false
+		visited[node] = true
+		return nil
+	}
+	return visit(name)
+}
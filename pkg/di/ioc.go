@@ -1,8 +1,8 @@
 package di
 
 import (
+	"context"
 	"reflect"
-	"sync"
 	"time"
 )
 
@@ -20,274 +20,416 @@ const (
 	REFRESHED Events = iota
 )
 
-var (
-	_refresh       sync.Map
-	_contextTypes  sync.Map
-	_context       sync.Map
-	_scopedContext sync.Map
-
-	_refreshMute sync.Mutex
-)
-
 type options struct {
 	scopeId uint64
 	ttl     time.Duration
+	legacy  bool
+	scope   *Scope
+	ctx     context.Context
 }
 
-type singleton[T any] struct {
-	ig       func() (instance *T, err error)
-	created  bool
-	instance *T
-	err      error
-	once     sync.Once
-}
+type factory[T any] func(options *options) (instance *T, err error)
 
-func (s *singleton[T]) getInstance() (instance *T, err error) {
-	s.once.Do(func() {
-		value, err := s.ig()
-		s.instance = value
-		s.err = err
-		s.created = true
-	})
-	return s.instance, s.err
+type Registry[T any] struct {
+	container *Container
 }
 
 func NewOptions(scopeId uint64, ttl time.Duration) *options {
-	return &options{scopeId, ttl}
+	return &options{scopeId: scopeId, ttl: ttl, legacy: true}
 }
 
-func AddSinleton[T any](service func() (instance *T, err error)) error {
+func (o *options) context() context.Context {
+	if o == nil {
+		return context.Background()
+	}
+	if o.ctx != nil {
+		return o.ctx
+	}
+	if o.scope != nil {
+		return o.scope.ctx
+	}
+	return context.Background()
+}
+
+func (o *options) withContext(ctx context.Context) *options {
+	if o == nil {
+		return &options{ctx: ctx}
+	}
+	out := *o
+	out.ctx = ctx
+	return &out
+}
+
+func (o *options) withScope(scope *Scope) *options {
+	if o == nil {
+		return &options{scope: scope}
+	}
+	out := *o
+	out.scope = scope
+	return &out
+}
+
+func factoryOf[T any](service func() (instance *T, err error)) factory[T] {
+	return func(options *options) (instance *T, err error) {
+		return service()
+	}
+}
+
+func For[T any](container *Container) Registry[T] {
+	return Registry[T]{container: container}
+}
+
+func (r Registry[T]) AddSinleton(service func() (instance *T, err error)) error {
 	name := nameOf[T]()
-	return AddSinletonWithName(name, service)
+	return r.AddSinletonWithName(name, service)
 }
 
-func AddSinletonWithName[T any](name string, service func() (instance *T, err error)) error {
+func (r Registry[T]) AddSinletonWithName(name string, service func() (instance *T, err error)) error {
+	return r.addSinleton(name, factoryOf(service))
+}
+
+func (r Registry[T]) addSinleton(name string, service factory[T]) error {
 	singleton := singleton[T]{
-		ig: service,
+		ig:        recorded(r.container, name, decorated(r.container, name, service)),
+		container: r.container,
+		name:      name,
 	}
-	if _, ok := _context.LoadOrStore(name, &singleton); ok {
+	if _, ok := r.container.context.LoadOrStore(name, &singleton); ok {
 		return objectAlreadyExistsError(name)
 	}
-	_contextTypes.Store(name, SINGLETON)
+	r.container.describe(name, nameOf[T](), true)
+	r.container.contextTypes.Store(name, SINGLETON)
+	r.container.resolvers.Store(name, r.resolverOf(name))
 	return nil
 }
 
-func RefreshSinleton[T any](service func(current *T) (instance *T, err error)) (*T, error) {
+func (r Registry[T]) RefreshSinleton(service func(current *T) (instance *T, err error)) (*T, error) {
 	name := nameOf[T]()
-	return RefreshSinletonWithName(name, service)
+	return r.RefreshSinletonWithName(name, service)
 }
 
-func RefreshSinletonWithName[T any](name string, newService func(current *T) (instance *T, err error)) (*T, error) {
-	_refreshMute.Lock()
-	defer _refreshMute.Unlock()
-	old, err := ResolveWithName[T](name, nil)
+func (r Registry[T]) RefreshSinletonWithName(name string, newService func(current *T) (instance *T, err error)) (*T, error) {
+	r.container.refreshMute.Lock()
+	defer r.container.refreshMute.Unlock()
+	old, err := r.ResolveWithName(name, nil)
 	if err != nil {
 		return nil, err
 	}
 	new, e := newService(old)
+	if e == nil && new != old {
+		inner := new
+		new, e = decorated(r.container, name, func(options *options) (instance *T, err error) {
+			return inner, nil
+		})(nil)
+	}
+	if e != nil {
+		r.container.record(name, e)
+		raiseRefreshed(r.container, RefreshEvent[T]{Name: name, Event: REFRESHED, Old: old, Err: e})
+		return old, e
+	}
+	if new == old {
+		r.container.disposer.untrack(old)
+	}
 	singleton := singleton[T]{
-		ig: func() (instance *T, err error) {
-			return new, e
+		ig: func(options *options) (instance *T, err error) {
+			return new, nil
 		},
+		container: r.container,
+		name:      name,
 	}
-	_context.Store(name, &singleton)
-	values, ok := _refresh.Load(name)
-	if ok {
-		for _, value := range values.([]func(Events)) {
-			value(REFRESHED)
+	r.container.describe(name, nameOf[T](), false)
+	r.container.record(name, nil)
+	r.container.context.Store(name, &singleton)
+	r.container.contextTypes.Store(name, SINGLETON)
+	r.container.resolvers.Store(name, r.resolverOf(name))
+	r.container.bump(name)
+	r.container.raise(name, REFRESHED)
+	raiseRefreshed(r.container, RefreshEvent[T]{Name: name, Event: REFRESHED, Old: old, New: new})
+	if old != nil && old != new {
+		err := r.container.disposer.dispose(old)
+		if err != nil {
+			return old, err
 		}
 	}
 	return old, nil
 }
 
-func OnRefresh[T any](cb func(Events)) {
+func (r Registry[T]) OnRefresh(cb func(Events)) {
 	name := nameOf[T]()
-	OnRefreshWithName(name, cb)
+	r.container.OnRefreshWithName(name, cb)
 }
 
-func OnRefreshWithName(name string, cb func(Events)) {
-	value, ok := _refresh.Load(name)
-	if !ok {
-		value = make([]func(Events), 0)
-	}
-	value = append(value.([]func(Events)), cb)
-	_refresh.Store(name, value)
+func (r Registry[T]) AddTransient(service func() (instance *T, err error)) error {
+	name := nameOf[T]()
+	return r.AddTransientWithName(name, service)
 }
 
-func AddTransient[T any](service func() (instance *T, err error)) error {
-	name := nameOf[T]()
-	return AddTransientWithName(name, service)
+func (r Registry[T]) AddTransientWithName(name string, service func() (instance *T, err error)) error {
+	return r.addTransient(name, factoryOf(service))
 }
 
-func AddTransientWithName[T any](name string, service func() (instance *T, err error)) error {
-	if _, ok := _context.Load(name); ok {
+func (r Registry[T]) addTransient(name string, service factory[T]) error {
+	if _, ok := r.container.context.LoadOrStore(name, recorded(r.container, name, decorated(r.container, name, service))); ok {
 		return objectAlreadyExistsError(name)
 	}
-	_context.Store(name, service)
-	_contextTypes.Store(name, TRANSIENT)
+	r.container.describe(name, nameOf[T](), true)
+	r.container.contextTypes.Store(name, TRANSIENT)
+	r.container.resolvers.Store(name, r.resolverOf(name))
 	return nil
 }
 
-func RefreshTransient[T any](service func() (instance *T, err error)) error {
+func (r Registry[T]) RefreshTransient(service func() (instance *T, err error)) error {
 	name := nameOf[T]()
-	return RefreshTransientWithName(name, service)
+	return r.RefreshTransientWithName(name, service)
 }
 
-func RefreshTransientWithName[T any](name string, service func() (instance *T, err error)) error {
-	_refreshMute.Lock()
-	defer _refreshMute.Unlock()
-	_context.Store(name, service)
-	_contextTypes.Store(name, TRANSIENT)
-	values, ok := _refresh.Load(name)
-	if ok {
-		for _, value := range values.([]func(Events)) {
-			value(REFRESHED)
-		}
-	}
+func (r Registry[T]) RefreshTransientWithName(name string, service func() (instance *T, err error)) error {
+	r.container.refreshMute.Lock()
+	defer r.container.refreshMute.Unlock()
+	r.container.describe(name, nameOf[T](), false)
+	r.container.context.Store(name, recorded(r.container, name, decorated(r.container, name, factoryOf(service))))
+	r.container.contextTypes.Store(name, TRANSIENT)
+	r.container.resolvers.Store(name, r.resolverOf(name))
+	r.container.bump(name)
+	r.container.raise(name, REFRESHED)
+	raiseRefreshed(r.container, RefreshEvent[T]{Name: name, Event: REFRESHED})
 	return nil
 }
 
-func AddScoped[T any](service func() (instance *T, err error)) error {
+func (r Registry[T]) AddScoped(service func() (instance *T, err error)) error {
 	name := nameOf[T]()
-	return AddScopedWithName(name, service)
+	return r.AddScopedWithName(name, service)
 }
 
-func AddScopedWithName[T any](name string, service func() (instance *T, err error)) error {
-	if _, ok := _context.LoadOrStore(name, service); ok {
+func (r Registry[T]) AddScopedWithName(name string, service func() (instance *T, err error)) error {
+	return r.addScoped(name, factoryOf(service))
+}
+
+func (r Registry[T]) addScoped(name string, service factory[T]) error {
+	if _, ok := r.container.context.LoadOrStore(name, recorded(r.container, name, decorated(r.container, name, service))); ok {
 		return objectAlreadyExistsError(name)
 	}
-	_contextTypes.Store(name, SCOPED)
+	r.container.describe(name, nameOf[T](), true)
+	r.container.contextTypes.Store(name, SCOPED)
+	r.container.resolvers.Store(name, r.resolverOf(name))
 	return nil
 }
 
-func RefreshScoped[T any](service func() (instance *T, err error)) error {
+func (r Registry[T]) RefreshScoped(service func() (instance *T, err error)) error {
 	name := nameOf[T]()
-	return RefreshScopedWithName(name, service)
+	return r.RefreshScopedWithName(name, service)
 }
 
-func RefreshScopedWithName[T any](name string, service func() (instance *T, err error)) error {
-	_refreshMute.Lock()
-	defer _refreshMute.Unlock()
-	_contextTypes.Store(name, SCOPED)
-	values, ok := _refresh.Load(name)
-	if ok {
-		for _, value := range values.([]func(Events)) {
-			value(REFRESHED)
-		}
-	}
+func (r Registry[T]) RefreshScopedWithName(name string, service func() (instance *T, err error)) error {
+	r.container.refreshMute.Lock()
+	defer r.container.refreshMute.Unlock()
+	r.container.describe(name, nameOf[T](), false)
+	r.container.context.Store(name, recorded(r.container, name, decorated(r.container, name, factoryOf(service))))
+	r.container.contextTypes.Store(name, SCOPED)
+	r.container.resolvers.Store(name, r.resolverOf(name))
+	r.container.bump(name)
+	r.container.raise(name, REFRESHED)
+	raiseRefreshed(r.container, RefreshEvent[T]{Name: name, Event: REFRESHED})
 	return nil
 }
 
-func Has[T any]() bool {
+func (r Registry[T]) Has() bool {
 	name := nameOf[T]()
-	_, ok := _context.Load(name)
-	return ok
-}
-
-func HasWithName(name string) bool {
-	_, ok := _context.Load(name)
-	return ok
+	return r.container.HasWithName(name)
 }
 
-func ResolveOrPanic[T any](options *options) *T {
-	value, err := Resolve[T](options)
+func (r Registry[T]) ResolveOrPanic(options *options) *T {
+	value, err := r.Resolve(options)
 	if err != nil {
 		panic(err)
 	}
 	return value
 }
 
-func ResolveWithNameOrPanic[T any](name string, options *options) *T {
-	value, err := ResolveWithName[T](name, options)
+func (r Registry[T]) ResolveWithNameOrPanic(name string, options *options) *T {
+	value, err := r.ResolveWithName(name, options)
 	if err != nil {
 		panic(err)
 	}
 	return value
 }
 
-func ResolveOrNil[T any](options *options) *T {
-	value, _ := Resolve[T](options)
+func (r Registry[T]) ResolveOrNil(options *options) *T {
+	value, _ := r.Resolve(options)
 	return value
 }
 
-func Resolve[T any](options *options) (instance *T, err error) {
+func (r Registry[T]) Resolve(options *options) (instance *T, err error) {
 	name := nameOf[T]()
-	return ResolveWithName[T](name, options)
+	return r.ResolveWithName(name, options)
 }
 
-func ResolveWithName[T any](name string, options *options) (instance *T, err error) {
-	lifeCycle, ok := _contextTypes.Load(name)
+func (r Registry[T]) ResolveWithName(name string, options *options) (instance *T, err error) {
+	_, lifeCycle, object, ok := r.container.lookup(name)
 	if !ok {
 		return nil, objectNotFoundError(name)
 	}
-	object, ok := _context.Load(name)
+	resolution := Resolution{
+		Name:      name,
+		Type:      nameOf[T](),
+		LifeCycle: lifeCycle,
+	}
+	if options != nil {
+		resolution.Scope = options.scope
+	}
+	value, err := r.container.intercept(resolution, func() (any, error) {
+		instance, err := r.resolve(lifeCycle, object, name, options)
+		return instance, err
+	})
+	if value == nil {
+		return nil, err
+	}
+	instance, ok = value.(*T)
 	if !ok {
-		return nil, objectNotFoundError(name)
+		return nil, invalidCastError(name)
 	}
+	return instance, err
+}
+
+func (r Registry[T]) resolve(lifeCycle LifeCycles, object any, name string, options *options) (instance *T, err error) {
 	switch lifeCycle {
 	case SINGLETON:
-		return resolveSingleton[T](object, name)
+		return resolveSingleton[T](object, options, name)
 	case TRANSIENT:
-		return resolveTransient[T](object, name)
+		return resolveTransient[T](object, options, name)
 	case SCOPED:
-		return resolveScoped[T](options, object, name)
+		return resolveScoped[T](r.container, options, object, name)
 	default:
 		return nil, nil
 	}
 }
 
-func CloseScope(option options) {
-	_scopedContext.Delete(option.scopeId)
+func (r Registry[T]) resolverOf(name string) func(options *options) (any, error) {
+	return func(options *options) (any, error) {
+		instance, err := r.ResolveWithName(name, options)
+		return instance, err
+	}
+}
+
+func AddSinleton[T any](service func() (instance *T, err error)) error {
+	return For[T](_default).AddSinleton(service)
 }
 
-func resolveSingleton[T any](object any, name string) (instance *T, err error) {
+func AddSinletonWithName[T any](name string, service func() (instance *T, err error)) error {
+	return For[T](_default).AddSinletonWithName(name, service)
+}
+
+func RefreshSinleton[T any](service func(current *T) (instance *T, err error)) (*T, error) {
+	return For[T](_default).RefreshSinleton(service)
+}
+
+func RefreshSinletonWithName[T any](name string, newService func(current *T) (instance *T, err error)) (*T, error) {
+	return For[T](_default).RefreshSinletonWithName(name, newService)
+}
+
+func OnRefresh[T any](cb func(Events)) {
+	For[T](_default).OnRefresh(cb)
+}
+
+func OnRefreshWithName(name string, cb func(Events)) {
+	_default.OnRefreshWithName(name, cb)
+}
+
+func AddTransient[T any](service func() (instance *T, err error)) error {
+	return For[T](_default).AddTransient(service)
+}
+
+func AddTransientWithName[T any](name string, service func() (instance *T, err error)) error {
+	return For[T](_default).AddTransientWithName(name, service)
+}
+
+func RefreshTransient[T any](service func() (instance *T, err error)) error {
+	return For[T](_default).RefreshTransient(service)
+}
+
+func RefreshTransientWithName[T any](name string, service func() (instance *T, err error)) error {
+	return For[T](_default).RefreshTransientWithName(name, service)
+}
+
+func AddScoped[T any](service func() (instance *T, err error)) error {
+	return For[T](_default).AddScoped(service)
+}
+
+func AddScopedWithName[T any](name string, service func() (instance *T, err error)) error {
+	return For[T](_default).AddScopedWithName(name, service)
+}
+
+func RefreshScoped[T any](service func() (instance *T, err error)) error {
+	return For[T](_default).RefreshScoped(service)
+}
+
+func RefreshScopedWithName[T any](name string, service func() (instance *T, err error)) error {
+	return For[T](_default).RefreshScopedWithName(name, service)
+}
+
+func Has[T any]() bool {
+	return For[T](_default).Has()
+}
+
+func HasWithName(name string) bool {
+	return _default.HasWithName(name)
+}
+
+func ResolveOrPanic[T any](options *options) *T {
+	return For[T](_default).ResolveOrPanic(options)
+}
+
+func ResolveWithNameOrPanic[T any](name string, options *options) *T {
+	return For[T](_default).ResolveWithNameOrPanic(name, options)
+}
+
+func ResolveOrNil[T any](options *options) *T {
+	return For[T](_default).ResolveOrNil(options)
+}
+
+func Resolve[T any](options *options) (instance *T, err error) {
+	return For[T](_default).Resolve(options)
+}
+
+func ResolveWithName[T any](name string, options *options) (instance *T, err error) {
+	return For[T](_default).ResolveWithName(name, options)
+}
+
+func CloseScope(option options) error {
+	return _default.CloseScope(option)
+}
+
+func resolveSingleton[T any](object any, options *options, name string) (instance *T, err error) {
 	value, ok := object.(*singleton[T])
 	if !ok {
 		return nil, invalidCastError(name)
 	}
-	inst, err := value.getInstance()
+	inst, err := value.getInstance(options.context())
 	return inst, err
 }
 
-func resolveTransient[T any](object any, name string) (instance *T, err error) {
-	value, ok := object.(func() (instance *T, err error))
+func resolveTransient[T any](object any, options *options, name string) (instance *T, err error) {
+	value, ok := object.(factory[T])
 	if !ok {
 		return nil, invalidCastError(name)
 	}
-	inst, err := value()
+	inst, err := value(options)
 	return inst, err
 }
 
-func resolveScoped[T any](options *options, object any, name string) (instance *T, err error) {
-	if options == nil {
-		return nil, missingRequiredParameter("Options")
-	}
-	scopedValue, ok := _scopedContext.Load(options.scopeId)
-	if ok {
-		if value, ok := scopedValue.(*T); ok {
-			return value, nil
-		}
-		return nil, invalidCastError(name)
+func resolveScoped[T any](container *Container, options *options, object any, name string) (instance *T, err error) {
+	scope, err := container.scopeOf(options)
+	if err != nil {
+		return nil, err
 	}
-	value, ok := object.(func() (instance *T, err error))
+	value, ok := object.(factory[T])
 	if !ok {
 		return nil, invalidCastError(name)
 	}
-	inst, err := value()
-	_scopedContext.Store(options.scopeId, inst)
-	time.AfterFunc(options.ttl, func() {
-		_scopedContext.Delete(options.scopeId)
-	})
-	return inst, err
+	return resolveInScope[T](scope, options.withScope(scope), value, name)
 }
 
 func nameOf[T any]() string {
 	var typeOfT *T
 	return reflect.TypeOf(typeOfT).Elem().String()
 }
-
-func init() {
-	_contextTypes = sync.Map{}
-	_context = sync.Map{}
-	_scopedContext = sync.Map{}
-}
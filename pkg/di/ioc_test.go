@@ -0,0 +1,674 @@
+package di
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ctorConn struct {
+	url string
+}
+
+type ctorRepo struct {
+	conn *ctorConn
+}
+
+type ctorService struct {
+	repo *ctorRepo
+	conn *ctorConn
+}
+
+type disposableConn struct {
+	name     string
+	closed   *[]string
+	draining bool
+}
+
+type disposableRepo struct {
+	closed *[]string
+}
+
+func (d *disposableConn) Drain() error {
+	if d.draining {
+		return errors.New("connection draining")
+	}
+	d.draining = true
+	*d.closed = append(*d.closed, d.name)
+	return nil
+}
+
+func (d *disposableConn) IsDraining() bool {
+	return d.draining
+}
+
+func (d *disposableRepo) Close() {
+	*d.closed = append(*d.closed, "repo")
+}
+
+type sink interface {
+	Name() string
+}
+
+type consoleSink struct{}
+
+type fileSink struct{}
+
+type sinkConsumer struct {
+	sink sink
+}
+
+func (consoleSink) Name() string {
+	return "console"
+}
+
+func (fileSink) Name() string {
+	return "file"
+}
+
+type cycleA struct{}
+type cycleB struct{}
+
+func TestConstructorInjection(t *testing.T) {
+	container := NewContainer()
+	err := For[ctorConn](container).AddSinletonWithName("ctor_conn", func() (*ctorConn, error) {
+		return &ctorConn{url: "nats://127.0.0.1:4222"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorRepo](container).AddSinletonConstructor(func(conn *ctorConn) (*ctorRepo, error) {
+		return &ctorRepo{conn: conn}, nil
+	}, "ctor_conn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorService](container).AddTransientConstructor(func(repo *ctorRepo, conn *ctorConn) (*ctorService, error) {
+		return &ctorService{repo: repo, conn: conn}, nil
+	}, "", "ctor_conn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service, err := For[ctorService](container).Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.repo.conn != service.conn {
+		t.Fatalf("expected the singleton connection to be shared")
+	}
+	dependencies := For[ctorService](container).DependenciesOf()
+	if len(dependencies) != 2 || dependencies[0] != nameOf[ctorRepo]() || dependencies[1] != "ctor_conn" {
+		t.Fatalf("unexpected dependencies %v", dependencies)
+	}
+}
+
+func TestConstructorCycle(t *testing.T) {
+	container := NewContainer()
+	err := For[cycleA](container).AddSinletonConstructor(func(*cycleB) (*cycleA, error) {
+		return &cycleA{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[cycleB](container).AddSinletonConstructor(func(*cycleA) (*cycleB, error) {
+		return &cycleB{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[cycleA](container).Resolve(nil)
+	if err == nil {
+		t.Fatalf("expected a circular dependency error")
+	}
+	if !strings.Contains(err.Error(), "di.cycleA -> di.cycleB -> di.cycleA") {
+		t.Fatalf("unexpected error %s", err)
+	}
+}
+
+func TestInvalidConstructor(t *testing.T) {
+	container := NewContainer()
+	err := For[ctorRepo](container).AddSinletonConstructorWithName("invalid_ctor", func(ctorConn) (*ctorRepo, error) {
+		return nil, nil
+	})
+	if err == nil {
+		t.Fatalf("expected an invalid dependency error")
+	}
+	err = For[ctorRepo](container).AddSinletonConstructorWithName("invalid_ctor", func() *ctorRepo {
+		return nil
+	})
+	if err == nil {
+		t.Fatalf("expected an invalid constructor error")
+	}
+}
+
+func TestChildContainer(t *testing.T) {
+	parent := NewContainer()
+	child := parent.NewChild()
+	err := For[ctorConn](parent).AddSinleton(func() (*ctorConn, error) {
+		return &ctorConn{url: "parent"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorRepo](child).AddSinletonConstructor(func(conn *ctorConn) (*ctorRepo, error) {
+		return &ctorRepo{conn: conn}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := For[ctorRepo](child).Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.conn.url != "parent" {
+		t.Fatalf("expected the child to fall back to the parent registration")
+	}
+	if For[ctorRepo](parent).Has() {
+		t.Fatalf("expected the parent not to see child registrations")
+	}
+	if NewContainer().HasWithName(nameOf[ctorConn]()) {
+		t.Fatalf("expected containers to be isolated")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	closed := make([]string, 0)
+	container := NewContainer()
+	err := For[disposableConn](container).AddSinleton(func() (*disposableConn, error) {
+		return &disposableConn{name: "conn", closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[disposableRepo](container).AddSinletonConstructor(func(*disposableConn) (*disposableRepo, error) {
+		return &disposableRepo{closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[disposableRepo](container).Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[disposableConn](container).RefreshSinleton(func(current *disposableConn) (*disposableConn, error) {
+		return &disposableConn{name: "refreshed", closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[disposableConn](container).Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = container.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(closed, ",") != "conn,refreshed,repo" {
+		t.Fatalf("unexpected disposal order %v", closed)
+	}
+}
+
+func TestRefreshFailure(t *testing.T) {
+	closed := make([]string, 0)
+	container := NewContainer()
+	err := For[disposableConn](container).AddSinleton(func() (*disposableConn, error) {
+		return &disposableConn{name: "conn", closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[disposableConn](container).Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[disposableConn](container).RefreshSinleton(func(current *disposableConn) (*disposableConn, error) {
+		return nil, errors.New("dial failed")
+	})
+	if err == nil || err.Error() != "dial failed" {
+		t.Fatalf("expected the refresh error but got %v", err)
+	}
+	conn, err := For[disposableConn](container).Resolve(nil)
+	if err != nil || conn.name != "conn" || len(closed) != 0 {
+		t.Fatalf("expected the current instance to survive a failed refresh")
+	}
+	_, err = For[disposableConn](container).RefreshSinleton(func(current *disposableConn) (*disposableConn, error) {
+		err := current.Drain()
+		if err != nil {
+			return nil, err
+		}
+		return &disposableConn{name: "refreshed", closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(closed, ",") != "conn" {
+		t.Fatalf("expected the drained instance to be disposed once but got %v", closed)
+	}
+}
+
+func TestScope(t *testing.T) {
+	closed := make([]string, 0)
+	container := NewContainer()
+	err := For[disposableConn](container).AddScoped(func() (*disposableConn, error) {
+		return &disposableConn{name: "conn", closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[disposableRepo](container).AddScopedConstructor(func(*disposableConn) (*disposableRepo, error) {
+		return &disposableRepo{closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := container.NewScope(context.Background())
+	conn, err := For[disposableConn](container).Resolve(scope.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[disposableRepo](container).Resolve(scope.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := For[disposableConn](container).Resolve(scope.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn != again {
+		t.Fatalf("expected one instance per scope")
+	}
+	err = scope.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(closed, ",") != "repo,conn" {
+		t.Fatalf("unexpected disposal order %v", closed)
+	}
+	_, err = For[disposableConn](container).Resolve(scope.Options())
+	if err == nil {
+		t.Fatalf("expected resolving from a closed scope to fail")
+	}
+}
+
+func TestBindings(t *testing.T) {
+	container := NewContainer()
+	err := For[consoleSink](container).AddSinleton(func() (*consoleSink, error) {
+		return &consoleSink{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[fileSink](container).AddTransientWithName("file", func() (*fileSink, error) {
+		return &fileSink{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = BindIn[sink, consoleSink](container)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = BindInWithName[sink, fileSink](container, "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = BindIn[sink, ctorConn](container)
+	if err == nil {
+		t.Fatalf("expected binding a type that does not implement the contract to fail")
+	}
+	sinks, err := For[sink](container).ResolveAll(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sinks) != 2 || sinks[0].Name() != "console" || sinks[1].Name() != "file" {
+		t.Fatalf("unexpected sinks %v", sinks)
+	}
+	err = For[sinkConsumer](container).AddTransientConstructor(func(sink sink) (*sinkConsumer, error) {
+		return &sinkConsumer{sink: sink}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer, err := For[sinkConsumer](container).Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if consumer.sink.Name() != "file" {
+		t.Fatalf("expected the most recent binding to be injected")
+	}
+}
+
+func TestLazy(t *testing.T) {
+	container := NewContainer()
+	err := For[ctorConn](container).AddSinleton(func() (*ctorConn, error) {
+		return &ctorConn{url: "first"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]RefreshEvent[ctorConn], 0)
+	For[ctorConn](container).OnRefreshed(func(e RefreshEvent[ctorConn]) {
+		events = append(events, e)
+	})
+	lazy := For[ctorConn](container).Lazy(nil)
+	if lazy.Value().url != "first" {
+		t.Fatalf("unexpected instance %v", lazy.Value())
+	}
+	_, err = For[ctorConn](container).RefreshSinleton(func(current *ctorConn) (*ctorConn, error) {
+		return &ctorConn{url: "second"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lazy.Value().url != "second" {
+		t.Fatalf("expected the lazy handle to follow the refresh")
+	}
+	if len(events) != 1 || events[0].Old.url != "first" || events[0].New.url != "second" {
+		t.Fatalf("unexpected refresh events %v", events)
+	}
+}
+
+func TestLazyLifeCycles(t *testing.T) {
+	container := NewContainer()
+	created := 0
+	err := For[ctorRepo](container).AddTransient(func() (*ctorRepo, error) {
+		created++
+		return &ctorRepo{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transient := For[ctorRepo](container).Lazy(nil)
+	if transient.Value() == transient.Value() || created != 2 {
+		t.Fatalf("expected the lazy handle to resolve a new transient on every call")
+	}
+	closed := make([]string, 0)
+	err = For[disposableConn](container).AddScoped(func() (*disposableConn, error) {
+		return &disposableConn{name: "scoped", closed: &closed}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scope := container.NewScope(context.Background())
+	scoped := For[disposableConn](container).Lazy(scope.Options())
+	if _, err := scoped.Get(); err != nil {
+		t.Fatal(err)
+	}
+	err = scope.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scoped.Get(); err == nil {
+		t.Fatalf("expected the lazy handle to report the closed scope")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	container := NewContainer()
+	err := For[ctorConn](container).AddSinletonWithName("conn", func() (*ctorConn, error) {
+		return &ctorConn{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorRepo](container).AddTransient(func() (*ctorRepo, error) {
+		return nil, errors.New("unreachable")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[ctorRepo](container).Resolve(nil)
+	if err == nil {
+		t.Fatalf("expected the factory error")
+	}
+	descriptors := container.Describe()
+	if len(descriptors) != 2 {
+		t.Fatalf("unexpected descriptors %v", descriptors)
+	}
+	conn, repo := descriptors[0], descriptors[1]
+	if conn.Name != "conn" || conn.Type != "di.ctorConn" || conn.LifeCycle != SINGLETON || conn.Created {
+		t.Fatalf("unexpected descriptor %v", conn)
+	}
+	if repo.LifeCycle != TRANSIENT || repo.Created || repo.LastError != "unreachable" {
+		t.Fatalf("unexpected descriptor %v", repo)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	container := NewContainer()
+	err := For[ctorConn](container).AddScoped(func() (*ctorConn, error) {
+		return &ctorConn{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorRepo](container).AddSinletonConstructor(func(*ctorConn) (*ctorRepo, error) {
+		return &ctorRepo{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorService](container).AddSinletonConstructor(func(*ctorRepo, *cycleA) (*ctorService, error) {
+		return &ctorService{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = container.Validate()
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	message := err.Error()
+	if !strings.Contains(message, "`di.ctorService` depends on `di.cycleA`") {
+		t.Fatalf("expected a missing dependency error, got %s", message)
+	}
+	if !strings.Contains(message, "the singleton `di.ctorRepo` depends on the scoped service `di.ctorConn`") {
+		t.Fatalf("expected a captive dependency error, got %s", message)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	container := NewContainer()
+	built := make([]string, 0)
+	err := For[ctorRepo](container).AddSinletonConstructor(func(*ctorConn) (*ctorRepo, error) {
+		built = append(built, "repo")
+		return &ctorRepo{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorConn](container).AddSinleton(func() (*ctorConn, error) {
+		built = append(built, "conn")
+		return &ctorConn{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = container.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(built, ",") != "conn,repo" {
+		t.Fatalf("unexpected build order %v", built)
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	container := NewContainer()
+	err := For[ctorConn](container).AddSinleton(func() (*ctorConn, error) {
+		return &ctorConn{url: "nats"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = For[ctorConn](container).Decorate(func(inner *ctorConn) (*ctorConn, error) {
+		return &ctorConn{url: inner.url + "+decorated"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolutions := make([]string, 0)
+	container.Intercept(func(resolution Resolution, next func() (any, error)) (any, error) {
+		resolutions = append(resolutions, resolution.Name)
+		return next()
+	})
+	conn := For[ctorConn](container).ResolveOrPanic(nil)
+	if conn.url != "nats+decorated" {
+		t.Fatalf("unexpected instance %v", conn)
+	}
+	_, err = For[ctorConn](container).RefreshSinleton(func(current *ctorConn) (*ctorConn, error) {
+		return &ctorConn{url: "refreshed"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = For[ctorConn](container).ResolveOrPanic(nil)
+	if conn.url != "refreshed+decorated" {
+		t.Fatalf("expected the decorator to survive the refresh, got %v", conn)
+	}
+	if len(resolutions) != 3 {
+		t.Fatalf("unexpected resolutions %v", resolutions)
+	}
+	var published *ctorConn
+	For[ctorConn](container).OnRefreshed(func(e RefreshEvent[ctorConn]) {
+		published = e.New
+	})
+	_, err = For[ctorConn](container).RefreshSinleton(func(current *ctorConn) (*ctorConn, error) {
+		return current, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn = For[ctorConn](container).ResolveOrPanic(nil)
+	if conn.url != "refreshed+decorated" || published != conn {
+		t.Fatalf("expected the current instance to be kept as is, got %v", conn)
+	}
+}
+
+func TestDecorateCreated(t *testing.T) {
+	parent := NewContainer()
+	child := parent.NewChild()
+	err := For[ctorConn](parent).AddSinleton(func() (*ctorConn, error) {
+		return &ctorConn{url: "nats"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy := For[ctorConn](child).Lazy(nil)
+	if lazy.Value().url != "nats" {
+		t.Fatalf("unexpected instance %v", lazy.Value())
+	}
+	err = For[ctorConn](child).Decorate(func(inner *ctorConn) (*ctorConn, error) {
+		return &ctorConn{url: inner.url + "+decorated"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn := For[ctorConn](parent).ResolveOrPanic(nil); conn.url != "nats+decorated" || lazy.Value() != conn {
+		t.Fatalf("expected the decorator to apply to the created singleton, got %v", conn)
+	}
+	_, err = For[ctorConn](parent).RefreshSinleton(func(current *ctorConn) (*ctorConn, error) {
+		return &ctorConn{url: "refreshed"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn := For[ctorConn](parent).ResolveOrPanic(nil); conn.url != "refreshed+decorated" {
+		t.Fatalf("expected the decorator to survive the refresh, got %v", conn)
+	}
+}
+
+func TestSingletonRetry(t *testing.T) {
+	container := NewContainer()
+	container.SetBackoff(Backoff{})
+	attempts := 0
+	err := For[ctorConn](container).AddSinletonWithContext(func(ctx context.Context) (*ctorConn, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, errors.New("unreachable")
+		}
+		return &ctorConn{}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = For[ctorConn](container).Resolve(nil)
+	if err == nil {
+		t.Fatalf("expected the first construction to fail")
+	}
+	_, err = For[ctorConn](container).Resolve(nil)
+	if err != nil {
+		t.Fatalf("expected the construction to be retried, got %s", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("unexpected attempts %d", attempts)
+	}
+}
+
+func TestResolveWithContext(t *testing.T) {
+	container := NewContainer()
+	release := make(chan struct{})
+	defer close(release)
+	err := For[ctorConn](container).AddSinletonWithContext(func(ctx context.Context) (*ctorConn, error) {
+		select {
+		case <-release:
+			return &ctorConn{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = For[ctorConn](container).ResolveWithContext(ctx, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to bound the resolution, got %v", err)
+	}
+}
+
+func TestResolveAfterCancelledConstruction(t *testing.T) {
+	container := NewContainer()
+	started := make(chan struct{}, 2)
+	var attempts atomic.Int32
+	err := For[ctorConn](container).AddSinletonWithContext(func(ctx context.Context) (*ctorConn, error) {
+		started <- struct{}{}
+		if attempts.Add(1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return &ctorConn{url: "second"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := For[ctorConn](container).ResolveWithContext(ctx, nil)
+		cancelled <- err
+	}()
+	<-started
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := For[ctorConn](container).ResolveWithContext(context.Background(), nil)
+		waiting <- err
+	}()
+	<-time.After(time.Millisecond * 50)
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled caller to fail, got %v", err)
+	}
+	if err := <-waiting; err != nil {
+		t.Fatalf("expected the waiting caller to construct the singleton, got %v", err)
+	}
+	conn, err := For[ctorConn](container).Resolve(nil)
+	if err != nil || conn.url != "second" {
+		t.Fatalf("expected the cancellation not to be recorded as a failure, got %v", err)
+	}
+}
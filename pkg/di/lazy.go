@@ -0,0 +1,157 @@
+package di
+
+import (
+	"sync/atomic"
+)
+
+type Provider[T any] func() (instance *T, err error)
+
+type RefreshEvent[T any] struct {
+	Name  string
+	Event Events
+	Old   *T
+	New   *T
+	Err   error
+}
+
+type lazyValue[T any] struct {
+	version  uint64
+	instance *T
+}
+
+type Lazy[T any] struct {
+	container *Container
+	name      string
+	options   *options
+	value     atomic.Pointer[lazyValue[T]]
+}
+
+func (r Registry[T]) Lazy(options *options) *Lazy[T] {
+	name := nameOf[T]()
+	return r.LazyWithName(name, options)
+}
+
+func (r Registry[T]) LazyWithName(name string, options *options) *Lazy[T] {
+	return &Lazy[T]{
+		container: r.container,
+		name:      name,
+		options:   options,
+	}
+}
+
+func (r Registry[T]) Provider(options *options) Provider[T] {
+	name := nameOf[T]()
+	return r.ProviderWithName(name, options)
+}
+
+func (r Registry[T]) ProviderWithName(name string, options *options) Provider[T] {
+	return func() (instance *T, err error) {
+		return r.ResolveWithName(name, options)
+	}
+}
+
+func (r Registry[T]) OnRefreshed(cb func(RefreshEvent[T])) {
+	name := nameOf[T]()
+	r.OnRefreshedWithName(name, cb)
+}
+
+func (r Registry[T]) OnRefreshedWithName(name string, cb func(RefreshEvent[T])) {
+	r.container.eventMute.Lock()
+	defer r.container.eventMute.Unlock()
+	value, ok := r.container.typedRefresh.Load(name)
+	if !ok {
+		value = make([]any, 0)
+	}
+	value = append(value.([]any), cb)
+	r.container.typedRefresh.Store(name, value)
+}
+
+func (l *Lazy[T]) Name() string {
+	return l.name
+}
+
+func (l *Lazy[T]) Get() (*T, error) {
+	return l.get(l.options)
+}
+
+// get caches singletons until they are refreshed and resolves every other life cycle
+// on each call so that transients stay fresh and closed scopes are reported.
+func (l *Lazy[T]) get(options *options) (*T, error) {
+	if _, lifeCycle, _, ok := l.container.lookup(l.name); !ok || lifeCycle != SINGLETON {
+		return For[T](l.container).ResolveWithName(l.name, options)
+	}
+	version := l.container.versionOf(l.name)
+	current := l.value.Load()
+	if current != nil && current.version == version {
+		return current.instance, nil
+	}
+	instance, err := For[T](l.container).ResolveWithName(l.name, options)
+	if err != nil {
+		return nil, err
+	}
+	l.value.Store(&lazyValue[T]{
+		version:  version,
+		instance: instance,
+	})
+	return instance, nil
+}
+
+func (l *Lazy[T]) Value() *T {
+	value, err := l.Get()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func (c *Container) versionOf(name string) uint64 {
+	var version uint64
+	for container := c; container != nil; container = container.parent {
+		value, ok := container.versions.Load(name)
+		if ok {
+			version += value.(*atomic.Uint64).Load()
+		}
+	}
+	return version
+}
+
+func (c *Container) bump(name string) {
+	value, _ := c.versions.LoadOrStore(name, &atomic.Uint64{})
+	value.(*atomic.Uint64).Add(1)
+}
+
+func raiseRefreshed[T any](container *Container, event RefreshEvent[T]) {
+	values, ok := container.typedRefresh.Load(event.Name)
+	if !ok {
+		return
+	}
+	for _, value := range values.([]any) {
+		if cb, ok := value.(func(RefreshEvent[T])); ok {
+			cb(event)
+		}
+	}
+}
+
+func NewLazy[T any](options *options) *Lazy[T] {
+	return For[T](_default).Lazy(options)
+}
+
+func NewLazyWithName[T any](name string, options *options) *Lazy[T] {
+	return For[T](_default).LazyWithName(name, options)
+}
+
+func NewProvider[T any](options *options) Provider[T] {
+	return For[T](_default).Provider(options)
+}
+
+func NewProviderWithName[T any](name string, options *options) Provider[T] {
+	return For[T](_default).ProviderWithName(name, options)
+}
+
+func OnRefreshed[T any](cb func(RefreshEvent[T])) {
+	For[T](_default).OnRefreshed(cb)
+}
+
+func OnRefreshedWithName[T any](name string, cb func(RefreshEvent[T])) {
+	For[T](_default).OnRefreshedWithName(name, cb)
+}
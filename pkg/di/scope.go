@@ -0,0 +1,140 @@
+package di
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type scopeKey struct{}
+
+type scopedInstance struct {
+	once     sync.Once
+	instance any
+	err      error
+}
+
+type Scope struct {
+	id        uint64
+	container *Container
+	ctx       context.Context
+	cancel    context.CancelFunc
+	instances sync.Map
+	disposer  disposer
+	closed    atomic.Bool
+}
+
+var (
+	_scopeId atomic.Uint64
+)
+
+func (c *Container) NewScope(ctx context.Context) *Scope {
+	scope := &Scope{
+		id:        _scopeId.Add(1),
+		container: c,
+	}
+	scope.ctx, scope.cancel = context.WithCancel(context.WithValue(ctx, scopeKey{}, scope))
+	c.scopes.Store(scope.id, scope)
+	go func() {
+		<-scope.ctx.Done()
+		_ = scope.Close()
+	}()
+	return scope
+}
+
+func NewScope(ctx context.Context) *Scope {
+	return _default.NewScope(ctx)
+}
+
+func ScopeFromContext(ctx context.Context) (*Scope, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	scope, ok := ctx.Value(scopeKey{}).(*Scope)
+	return scope, ok && scope != nil
+}
+
+func (s *Scope) Id() uint64 {
+	return s.id
+}
+
+func (s *Scope) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Scope) Options() *options {
+	return &options{scope: s}
+}
+
+func (s *Scope) IsClosed() bool {
+	return s.closed.Load()
+}
+
+func (s *Scope) Close() error {
+	return s.close(context.Background())
+}
+
+func (s *Scope) close(ctx context.Context) error {
+	if !s.closed.CompareAndSwap(false, true) {
+		return nil
+	}
+	s.cancel()
+	s.container.scopes.Delete(s.id)
+	return s.disposer.disposeAll(ctx)
+}
+
+func (c *Container) scopeOf(options *options) (*Scope, error) {
+	if options == nil {
+		return nil, missingRequiredParameter("Options")
+	}
+	if options.scope != nil {
+		return options.scope, nil
+	}
+	if scope, ok := ScopeFromContext(options.ctx); ok {
+		return scope, nil
+	}
+	if !options.legacy {
+		return nil, missingRequiredParameter("Scope")
+	}
+	value, ok := c.scopedContext.Load(options.scopeId)
+	if ok {
+		return value.(*Scope), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), options.ttl)
+	scope := c.NewScope(ctx)
+	if value, loaded := c.scopedContext.LoadOrStore(options.scopeId, scope); loaded {
+		cancel()
+		return value.(*Scope), nil
+	}
+	scopeId := options.scopeId
+	go func() {
+		<-scope.ctx.Done()
+		cancel()
+		c.scopedContext.Delete(scopeId)
+	}()
+	return scope, nil
+}
+
+func resolveInScope[T any](scope *Scope, options *options, service factory[T], name string) (instance *T, err error) {
+	if scope.IsClosed() {
+		return nil, scopeClosedError(name)
+	}
+	value, _ := scope.instances.LoadOrStore(name, &scopedInstance{})
+	entry := value.(*scopedInstance)
+	entry.once.Do(func() {
+		inst, err := service(options)
+		entry.instance = inst
+		entry.err = err
+		if err == nil && inst != nil {
+			scope.disposer.track(name, inst)
+		}
+	})
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	inst, ok := entry.instance.(*T)
+	if !ok {
+		return nil, invalidCastError(name)
+	}
+	return inst, nil
+}
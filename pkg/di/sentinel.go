@@ -2,6 +2,7 @@ package di
 
 import (
 	"fmt"
+	"strings"
 )
 
 func objectNotFoundError(name string) error {
@@ -19,3 +20,63 @@ func invalidCastError(name string) error {
 func missingRequiredParameter(name string) error {
 	return fmt.Errorf("the `%s` parameter is required for scoped services", name)
 }
+
+func invalidConstructorError(name string) error {
+	return fmt.Errorf("the constructor of `%s` must be a function that returns (*%s, error)", name, name)
+}
+
+func invalidDependencyError(name string, index int) error {
+	return fmt.Errorf("parameter %d of the constructor of `%s` must be a pointer or an interface type", index, name)
+}
+
+func tooManyDependenciesError(name string) error {
+	return fmt.Errorf("more dependency names than constructor parameters were given for `%s`", name)
+}
+
+func circularDependencyError(path []string) error {
+	return fmt.Errorf("circular dependency detected `%s`", strings.Join(path, " -> "))
+}
+
+func disposeError(name string, err error) error {
+	return fmt.Errorf("failed to dispose `%s`: %w", name, err)
+}
+
+func scopeClosedError(name string) error {
+	return fmt.Errorf("cannot resolve `%s` from a scope that has already been closed", name)
+}
+
+func invalidContractError(name string) error {
+	return fmt.Errorf("`%s` is not an interface type", name)
+}
+
+func notImplementedError(name string, contract string) error {
+	return fmt.Errorf("`*%s` does not implement `%s`", name, contract)
+}
+
+func bindingAlreadyExistsError(contract string, name string) error {
+	return fmt.Errorf("`%s` has already been bound to `%s`", name, contract)
+}
+
+func bindingNotFoundError(contract string, name string) error {
+	return fmt.Errorf("`%s` has not been bound to `%s`", name, contract)
+}
+
+func missingDependencyError(name string, dependency string) error {
+	return fmt.Errorf("`%s` depends on `%s` which has not been registered", name, dependency)
+}
+
+func missingBindingError(contract string, name string) error {
+	return fmt.Errorf("`%s` is bound to `%s` which has not been registered", contract, name)
+}
+
+func captiveDependencyError(path []string) error {
+	return fmt.Errorf("the singleton `%s` depends on the scoped service `%s` through `%s`", path[0], path[len(path)-1], strings.Join(path, " -> "))
+}
+
+func buildError(name string, err error) error {
+	return fmt.Errorf("failed to build `%s`: %w", name, err)
+}
+
+func constructionPanicError(name string) error {
+	return fmt.Errorf("the factory of `%s` panicked", name)
+}
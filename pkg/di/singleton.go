@@ -0,0 +1,198 @@
+package di
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+type singleton[T any] struct {
+	ig        factory[T]
+	container *Container
+	name      string
+	mute      sync.Mutex
+	created   bool
+	instance  *T
+	err       error
+	failures  int
+	retryAt   time.Time
+	pending   chan struct{}
+}
+
+type singletonResult[T any] struct {
+	instance *T
+	err      error
+}
+
+var (
+	DefaultBackoff = Backoff{
+		Initial:    time.Millisecond * 100,
+		Max:        time.Second * 30,
+		Multiplier: 2,
+	}
+)
+
+func (b Backoff) Delay(failures int) time.Duration {
+	if failures <= 0 || b.Initial <= 0 {
+		return 0
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(b.Initial) * math.Pow(multiplier, float64(failures-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(delay)
+}
+
+func (c *Container) SetBackoff(backoff Backoff) {
+	c.backoffMute.Lock()
+	defer c.backoffMute.Unlock()
+	c.backoff = &backoff
+}
+
+func (c *Container) backoffOf() Backoff {
+	for container := c; container != nil; container = container.parent {
+		container.backoffMute.Lock()
+		backoff := container.backoff
+		container.backoffMute.Unlock()
+		if backoff != nil {
+			return *backoff
+		}
+	}
+	return DefaultBackoff
+}
+
+func SetBackoff(backoff Backoff) {
+	_default.SetBackoff(backoff)
+}
+
+// getInstance constructs the singleton at most once at a time. Callers that arrive
+// while a construction is in flight wait for it or for their own context, and a
+// failed construction is retried once the backoff of the container has elapsed.
+func (s *singleton[T]) getInstance(ctx context.Context) (instance *T, err error) {
+	for {
+		s.mute.Lock()
+		if s.created {
+			s.mute.Unlock()
+			return s.instance, nil
+		}
+		if s.pending != nil {
+			pending := s.pending
+			s.mute.Unlock()
+			select {
+			case <-pending:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if s.err != nil && time.Now().Before(s.retryAt) {
+			err := s.err
+			s.mute.Unlock()
+			return nil, err
+		}
+		pending := make(chan struct{})
+		s.pending = pending
+		s.mute.Unlock()
+		options := &options{ctx: context.WithValue(ctx, scopeKey{}, (*Scope)(nil))}
+		if ctx.Done() == nil {
+			return s.construct(pending, options)
+		}
+		done := make(chan singletonResult[T], 1)
+		go func() {
+			instance, err := s.construct(pending, options)
+			done <- singletonResult[T]{instance, err}
+		}()
+		select {
+		case result := <-done:
+			return result.instance, result.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (s *singleton[T]) construct(pending chan struct{}, options *options) (instance *T, err error) {
+	completed := false
+	defer func() {
+		if !completed {
+			s.complete(pending, nil, constructionPanicError(s.name))
+		}
+	}()
+	instance, err = s.ig(options)
+	completed = true
+	if err != nil && options.ctx.Err() != nil && errors.Is(err, options.ctx.Err()) {
+		s.abandon(pending)
+		return nil, err
+	}
+	s.complete(pending, instance, err)
+	return instance, err
+}
+
+// abandon releases the callers that waited on a construction that gave up because of
+// the context of the caller that started it, without counting it as a failure, so
+// that they start an attempt of their own.
+func (s *singleton[T]) abandon(pending chan struct{}) {
+	s.mute.Lock()
+	defer s.mute.Unlock()
+	s.pending = nil
+	close(pending)
+}
+
+func (s *singleton[T]) complete(pending chan struct{}, instance *T, err error) {
+	s.mute.Lock()
+	defer s.mute.Unlock()
+	s.pending = nil
+	close(pending)
+	if err != nil {
+		s.err = err
+		s.failures++
+		s.retryAt = time.Now().Add(s.container.backoffOf().Delay(s.failures))
+		return
+	}
+	s.created = true
+	s.instance = instance
+	s.err = nil
+	s.failures = 0
+	if instance != nil {
+		s.container.disposer.track(s.name, instance)
+	}
+}
+
+// decorate registers the decorator while no construction is in flight, so that a
+// construction either sees it or has already completed, in which case the decorator
+// is applied to the created instance.
+func (s *singleton[T]) decorate(decorator func(inner *T) (instance *T, err error), register func()) (bool, error) {
+	for {
+		s.mute.Lock()
+		if s.pending == nil {
+			break
+		}
+		pending := s.pending
+		s.mute.Unlock()
+		<-pending
+	}
+	defer s.mute.Unlock()
+	if !s.created {
+		register()
+		return false, nil
+	}
+	instance, err := decorator(s.instance)
+	if err != nil {
+		return false, err
+	}
+	register()
+	s.instance = instance
+	return true, nil
+}
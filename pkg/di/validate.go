@@ -0,0 +1,128 @@
+package di
+
+import (
+	"errors"
+	"sort"
+)
+
+func (c *Container) Validate() error {
+	errs := make([]error, 0)
+	inCycle := make(map[string]bool)
+	for _, node := range c.Graph() {
+		for _, dependency := range node.Dependencies {
+			if !c.HasWithName(dependency) && len(c.bindingsOf(dependency)) == 0 {
+				errs = append(errs, missingDependencyError(node.Name, dependency))
+			}
+		}
+		if !inCycle[node.Name] {
+			if cycle := c.findCycle(node.Name); cycle != nil {
+				for _, name := range cycle {
+					inCycle[name] = true
+				}
+				errs = append(errs, circularDependencyError(cycle))
+				continue
+			}
+		}
+		if node.LifeCycle == SINGLETON {
+			if path := c.findScoped(node.Name); path != nil {
+				errs = append(errs, captiveDependencyError(path))
+			}
+		}
+	}
+	for _, contract := range c.contracts() {
+		for _, name := range c.bindingsOf(contract) {
+			if !c.HasWithName(name) {
+				errs = append(errs, missingBindingError(contract, name))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Container) Build() error {
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, name := range c.buildOrder() {
+		_, lifeCycle, _, ok := c.lookup(name)
+		if !ok || lifeCycle != SINGLETON {
+			continue
+		}
+		_, err := c.resolveAny(name, nil)
+		if err != nil {
+			errs = append(errs, buildError(name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func Validate() error {
+	return _default.Validate()
+}
+
+func Build() error {
+	return _default.Build()
+}
+
+func (c *Container) buildOrder() []string {
+	order := make([]string, 0)
+	visited := make(map[string]bool)
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, dependency := range c.DependenciesOfName(name) {
+			visit(dependency)
+		}
+		order = append(order, name)
+	}
+	for _, node := range c.Graph() {
+		visit(node.Name)
+	}
+	return order
+}
+
+func (c *Container) findScoped(name string) []string {
+	visited := make(map[string]bool)
+	var visit func(node string, path []string) []string
+	visit = func(node string, path []string) []string {
+		if visited[node] {
+			return nil
+		}
+		visited[node] = true
+		path = append(path, node)
+		if len(path) > 1 {
+			if _, lifeCycle, _, ok := c.lookup(node); ok && lifeCycle == SCOPED {
+				return path
+			}
+		}
+		for _, dependency := range c.DependenciesOfName(node) {
+			if found := visit(dependency, path); found != nil {
+				return found
+			}
+		}
+		return nil
+	}
+	return visit(name, make([]string, 0))
+}
+
+func (c *Container) contracts() []string {
+	seen := make(map[string]bool)
+	contracts := make([]string, 0)
+	for container := c; container != nil; container = container.parent {
+		container.bindings.Range(func(key, value any) bool {
+			contract := key.(string)
+			if !seen[contract] {
+				seen[contract] = true
+				contracts = append(contracts, contract)
+			}
+			return true
+		})
+	}
+	sort.Strings(contracts)
+	return contracts
+}
@@ -0,0 +1,180 @@
+package fault
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+type Codes string
+
+const (
+	UNKNOWN             Codes = "UNKNOWN"
+	INTERNAL            Codes = "INTERNAL"
+	INVALID_ARGUMENT    Codes = "INVALID_ARGUMENT"
+	NOT_FOUND           Codes = "NOT_FOUND"
+	ALREADY_EXISTS      Codes = "ALREADY_EXISTS"
+	FAILED_PRECONDITION Codes = "FAILED_PRECONDITION"
+	UNAUTHENTICATED     Codes = "UNAUTHENTICATED"
+	PERMISSION_DENIED   Codes = "PERMISSION_DENIED"
+	RESOURCE_EXHAUSTED  Codes = "RESOURCE_EXHAUSTED"
+	CANCELED            Codes = "CANCELED"
+	DEADLINE_EXCEEDED   Codes = "DEADLINE_EXCEEDED"
+	UNAVAILABLE         Codes = "UNAVAILABLE"
+	UNIMPLEMENTED       Codes = "UNIMPLEMENTED"
+)
+
+const (
+	CODE_HEADER      = "error-code"
+	MESSAGE_HEADER   = "error"
+	RETRYABLE_HEADER = "error-retryable"
+	DETAILS_HEADER   = "error-details"
+	DETAIL_HEADER    = "error-detail"
+)
+
+var (
+	_httpStatus = map[Codes]int{
+		UNKNOWN:             http.StatusInternalServerError,
+		INTERNAL:            http.StatusInternalServerError,
+		INVALID_ARGUMENT:    http.StatusBadRequest,
+		NOT_FOUND:           http.StatusNotFound,
+		ALREADY_EXISTS:      http.StatusConflict,
+		FAILED_PRECONDITION: http.StatusPreconditionFailed,
+		UNAUTHENTICATED:     http.StatusUnauthorized,
+		PERMISSION_DENIED:   http.StatusForbidden,
+		RESOURCE_EXHAUSTED:  http.StatusTooManyRequests,
+		CANCELED:            499,
+		DEADLINE_EXCEEDED:   http.StatusGatewayTimeout,
+		UNAVAILABLE:         http.StatusServiceUnavailable,
+		UNIMPLEMENTED:       http.StatusNotImplemented,
+	}
+)
+
+// Error is the error that travels between a service and its callers. It is encoded
+// into the headers of the response by the service and decoded back by the proxy.
+type Error struct {
+	Status    string            `json:"status,omitempty"`
+	Code      Codes             `json:"code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Retryable bool              `json:"retryable"`
+	Detail    *anypb.Any        `json:"-"`
+}
+
+func New(code Codes, message string) *Error {
+	return &Error{
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) WithDetails(key string, value string) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]string)
+	}
+	e.Details[key] = value
+	return e
+}
+
+func (e *Error) WithRetryable(retryable bool) *Error {
+	e.Retryable = retryable
+	return e
+}
+
+func (e *Error) WithDetail(detail proto.Message) (*Error, error) {
+	any, err := anypb.New(detail)
+	if err != nil {
+		return nil, err
+	}
+	e.Detail = any
+	return e, nil
+}
+
+func (e *Error) UnpackDetail(detail proto.Message) error {
+	if e.Detail == nil {
+		return missingDetailError()
+	}
+	return e.Detail.UnmarshalTo(detail)
+}
+
+func (e *Error) HTTPStatus() int {
+	return HTTPStatus(e.Code)
+}
+
+func (e *Error) Header() map[string]string {
+	header := map[string]string{
+		CODE_HEADER:      string(e.Code),
+		MESSAGE_HEADER:   e.Message,
+		RETRYABLE_HEADER: strconv.FormatBool(e.Retryable),
+	}
+	if len(e.Details) != 0 {
+		details, err := json.Marshal(e.Details)
+		if err == nil {
+			header[DETAILS_HEADER] = string(details)
+		}
+	}
+	if e.Detail != nil {
+		detail, err := proto.Marshal(e.Detail)
+		if err == nil {
+			header[DETAIL_HEADER] = base64.StdEncoding.EncodeToString(detail)
+		}
+	}
+	return header
+}
+
+// From converts any error to an Error. Context errors are mapped to their codes and
+// every other error that is not already an Error becomes an INTERNAL error.
+func From(err error) *Error {
+	var fault *Error
+	if errors.As(err, &fault) {
+		return fault
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return New(DEADLINE_EXCEEDED, err.Error()).WithRetryable(true)
+	}
+	if errors.Is(err, context.Canceled) {
+		return New(CANCELED, err.Error())
+	}
+	return New(INTERNAL, err.Error())
+}
+
+func FromHeader(header nats.Header) *Error {
+	fault := New(Codes(header.Get(CODE_HEADER)), header.Get(MESSAGE_HEADER))
+	if fault.Code == "" {
+		fault.Code = UNKNOWN
+	}
+	fault.Status = header.Get("status")
+	fault.Retryable, _ = strconv.ParseBool(header.Get(RETRYABLE_HEADER))
+	if details := header.Get(DETAILS_HEADER); details != "" {
+		_ = json.Unmarshal([]byte(details), &fault.Details)
+	}
+	if detail := header.Get(DETAIL_HEADER); detail != "" {
+		bytes, err := base64.StdEncoding.DecodeString(detail)
+		if err == nil {
+			any := &anypb.Any{}
+			if proto.Unmarshal(bytes, any) == nil {
+				fault.Detail = any
+			}
+		}
+	}
+	return fault
+}
+
+func HTTPStatus(code Codes) int {
+	status, ok := _httpStatus[code]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
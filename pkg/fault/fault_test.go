@@ -0,0 +1,40 @@
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRoundTrip(t *testing.T) {
+	fault, err := New(NOT_FOUND, `user "1" not found`).WithDetails("id", "1").WithRetryable(true).WithDetail(wrapperspb.String("detail"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := nats.Header{}
+	header.Set("status", "FAIL:HANDLE")
+	for key, value := range fault.Header() {
+		header.Set(key, value)
+	}
+	var decoded *Error
+	if !errors.As(fmt.Errorf("wrapped: %w", FromHeader(header)), &decoded) {
+		t.Fatalf("expected the decoded error to be a fault")
+	}
+	if decoded.Code != NOT_FOUND || decoded.Message != fault.Message || decoded.Details["id"] != "1" || !decoded.Retryable || decoded.Status != "FAIL:HANDLE" {
+		t.Fatalf("unexpected error %v", decoded)
+	}
+	detail := &wrapperspb.StringValue{}
+	if err := decoded.UnpackDetail(detail); err != nil || detail.Value != "detail" {
+		t.Fatalf("unexpected detail %v %v", detail, err)
+	}
+	if decoded.HTTPStatus() != http.StatusNotFound {
+		t.Fatalf("unexpected http status %d", decoded.HTTPStatus())
+	}
+	if From(fmt.Errorf("failed")).Code != INTERNAL || FromHeader(nats.Header{}).Code != UNKNOWN {
+		t.Fatalf("unexpected codes")
+	}
+}
@@ -0,0 +1,7 @@
+package fault
+
+import "fmt"
+
+func missingDetailError() error {
+	return fmt.Errorf("the error does not carry a detail")
+}
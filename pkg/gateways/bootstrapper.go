@@ -12,6 +12,7 @@ var (
 )
 
 func Bootstrap(app *fiber.App) {
+	app.Use(scopeHandler)
 	for _, value := range _gateways {
 		value(app)
 	}
@@ -0,0 +1,14 @@
+package gateways
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/vedadiyan/goal/pkg/di"
+)
+
+func UseDiagnostics(uri string) {
+	Register(uri, fiber.MethodGet, DiagnosticsHandler)
+}
+
+func DiagnosticsHandler(c *fiber.Ctx) error {
+	return c.JSON(di.Describe())
+}
@@ -0,0 +1,22 @@
+package gateways
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/vedadiyan/goal/pkg/health"
+)
+
+func UseHealth(livenessURI string, readinessURI string) {
+	health.Expose()
+	Register(livenessURI, fiber.MethodGet, probeHandler(health.Live))
+	Register(readinessURI, fiber.MethodGet, probeHandler(health.Ready))
+}
+
+func probeHandler(probe func(ctx context.Context) health.Report) func(c *fiber.Ctx) error {
+	return func(c *fiber.Ctx) error {
+		report := probe(c.UserContext())
+		c.Status(report.HTTPStatus())
+		return c.JSON(report)
+	}
+}
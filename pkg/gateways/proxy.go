@@ -2,11 +2,13 @@ package gateways
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/vedadiyan/goal/pkg/fault"
 	"github.com/vedadiyan/goal/pkg/protoutil"
 	protoval "github.com/vedadiyan/goal/pkg/protoval"
 	"github.com/vedadiyan/goal/pkg/proxy"
@@ -88,6 +90,11 @@ func Single[TRequest any, TResponse any](app *fiber.App, uri string, method stri
 		}
 		res, err := proxy.Send(req)
 		if err != nil {
+			var fe *fault.Error
+			if errors.As(err, &fe) {
+				c.Status(fe.HTTPStatus())
+				return c.JSON(fe)
+			}
 			c.Status(fiber.StatusInternalServerError)
 			c.Response().Header.Add("Content-Type", "application/json")
 			_ = c.Send([]byte(err.Error()))
@@ -143,7 +150,7 @@ func Aggregated[TRequest any, TResponse any](app *fiber.App, uri string, method
 				if err != nil {
 					mut.Lock()
 					out[key] = map[string]any{
-						"error": err.Error(),
+						"error": errorOf(err),
 					}
 					return
 				}
@@ -165,6 +172,14 @@ func Aggregated[TRequest any, TResponse any](app *fiber.App, uri string, method
 	return proxies
 }
 
+func errorOf(err error) any {
+	var fe *fault.Error
+	if errors.As(err, &fe) {
+		return fe
+	}
+	return err.Error()
+}
+
 func Forward[TRequest any, TResponse any](uri string, method string, to any, options ...GatewayOption) {
 	_gateways = append(_gateways, func(app *fiber.App) {
 		switch t := to.(type) {
@@ -0,0 +1,17 @@
+package gateways
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/vedadiyan/goal/pkg/di"
+)
+
+func Scope(c *fiber.Ctx) (*di.Scope, bool) {
+	return di.ScopeFromContext(c.UserContext())
+}
+
+func scopeHandler(c *fiber.Ctx) error {
+	scope := di.NewScope(c.UserContext())
+	defer scope.Close()
+	c.SetUserContext(scope.Context())
+	return c.Next()
+}
@@ -0,0 +1,50 @@
+package health
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+)
+
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func NATS(conn *nats.Conn) Check {
+	return func(ctx context.Context) error {
+		if conn == nil {
+			return notConfiguredError("nats")
+		}
+		if status := conn.Status(); status != nats.CONNECTED {
+			return disconnectedError(status.String())
+		}
+		return nil
+	}
+}
+
+func JetStream(conn *nats.Conn, stream string) Check {
+	return func(ctx context.Context) error {
+		if conn == nil {
+			return notConfiguredError("jetstream")
+		}
+		js, err := conn.JetStream()
+		if err != nil {
+			return err
+		}
+		if stream == "" {
+			_, err = js.AccountInfo(nats.Context(ctx))
+			return err
+		}
+		_, err = js.StreamInfo(stream, nats.Context(ctx))
+		return err
+	}
+}
+
+func Ping(pinger Pinger) Check {
+	return func(ctx context.Context) error {
+		if pinger == nil {
+			return notConfiguredError("ping")
+		}
+		return pinger.Ping(ctx)
+	}
+}
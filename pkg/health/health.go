@@ -0,0 +1,186 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type States string
+
+type Probes int
+
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Status   States `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration"`
+}
+
+type Report struct {
+	Status States            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+const (
+	UP   States = "up"
+	DOWN States = "down"
+)
+
+const (
+	LIVENESS Probes = iota
+	READINESS
+)
+
+const (
+	LIVENESS_PATH  = "/healthz"
+	READINESS_PATH = "/readyz"
+)
+
+var (
+	_checks  map[Probes]map[string]Check
+	_mut     sync.RWMutex
+	_exposed atomic.Bool
+	_timeout = time.Second * 5
+)
+
+func init() {
+	_mut.Lock()
+	_checks = map[Probes]map[string]Check{
+		LIVENESS:  make(map[string]Check),
+		READINESS: make(map[string]Check),
+	}
+	_mut.Unlock()
+}
+
+func AddLiveness(name string, check Check) {
+	add(LIVENESS, name, check)
+}
+
+func AddReadiness(name string, check Check) {
+	add(READINESS, name, check)
+}
+
+func Remove(name string) {
+	_mut.Lock()
+	defer _mut.Unlock()
+	for _, checks := range _checks {
+		delete(checks, name)
+	}
+}
+
+func Live(ctx context.Context) Report {
+	return evaluate(ctx, LIVENESS)
+}
+
+func Ready(ctx context.Context) Report {
+	return evaluate(ctx, LIVENESS, READINESS)
+}
+
+func Expose() {
+	_exposed.Store(true)
+}
+
+func Exposed() bool {
+	return _exposed.Load()
+}
+
+func Handler() http.Handler {
+	Expose()
+	mux := http.NewServeMux()
+	mux.HandleFunc(LIVENESS_PATH, probe(Live))
+	mux.HandleFunc(READINESS_PATH, probe(Ready))
+	return mux
+}
+
+func Listen(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{
+		Handler:           Handler(),
+		ReadHeaderTimeout: _timeout,
+	}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return server, nil
+}
+
+func (r Report) HTTPStatus() int {
+	if r.Status == UP {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func add(probe Probes, name string, check Check) {
+	_mut.Lock()
+	defer _mut.Unlock()
+	_checks[probe][name] = check
+}
+
+func evaluate(ctx context.Context, probes ...Probes) Report {
+	checks := make(map[string]Check)
+	_mut.RLock()
+	for _, probe := range probes {
+		for name, check := range _checks[probe] {
+			checks[name] = check
+		}
+	}
+	_mut.RUnlock()
+	report := Report{
+		Status: UP,
+		Checks: make(map[string]Result),
+	}
+	var wg sync.WaitGroup
+	var mut sync.Mutex
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := run(ctx, name, check)
+			mut.Lock()
+			defer mut.Unlock()
+			report.Checks[name] = result
+			if result.Status != UP {
+				report.Status = DOWN
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+func run(ctx context.Context, name string, check Check) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, _timeout)
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start).Nanoseconds()
+		if recovered := recover(); recovered != nil {
+			result.Status = DOWN
+			result.Error = panicError(name, recovered).Error()
+		}
+	}()
+	err := check(ctx)
+	if err != nil {
+		return Result{Status: DOWN, Error: err.Error()}
+	}
+	return Result{Status: UP}
+}
+
+func probe(fn func(ctx context.Context) Report) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := fn(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(report.HTTPStatus())
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
@@ -0,0 +1,60 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbes(t *testing.T) {
+	AddLiveness("test.live", func(ctx context.Context) error {
+		return nil
+	})
+	AddReadiness("test.ready", func(ctx context.Context) error {
+		return errors.New("warming up")
+	})
+	AddReadiness("test.panic", func(ctx context.Context) error {
+		panic("boom")
+	})
+	t.Cleanup(func() {
+		Remove("test.live")
+		Remove("test.ready")
+		Remove("test.panic")
+	})
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	if !Exposed() {
+		t.Fatalf("expected the handler to expose the probes")
+	}
+	report := get(t, server.URL+LIVENESS_PATH, http.StatusOK)
+	if report.Status != UP || len(report.Checks) != 1 {
+		t.Fatalf("unexpected liveness report %v", report)
+	}
+	report = get(t, server.URL+READINESS_PATH, http.StatusServiceUnavailable)
+	if report.Status != DOWN || report.Checks["test.live"].Status != UP || report.Checks["test.ready"].Error != "warming up" || report.Checks["test.panic"].Status != DOWN {
+		t.Fatalf("unexpected readiness report %v", report)
+	}
+	Remove("test.ready")
+	Remove("test.panic")
+	get(t, server.URL+READINESS_PATH, http.StatusOK)
+}
+
+func get(t *testing.T, url string, status int) Report {
+	t.Helper()
+	res, err := http.Get(url) // #nosec G107
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != status {
+		t.Fatalf("expected status %d but got %d", status, res.StatusCode)
+	}
+	report := Report{}
+	if err := json.NewDecoder(res.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
@@ -0,0 +1,15 @@
+package health
+
+import "fmt"
+
+func disconnectedError(status string) error {
+	return fmt.Errorf("connection is %s", status)
+}
+
+func notConfiguredError(check string) error {
+	return fmt.Errorf("%s check has nothing to probe", check)
+}
+
+func panicError(name string, recovered any) error {
+	return fmt.Errorf("%s check panicked: %v", name, recovered)
+}
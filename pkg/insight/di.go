@@ -0,0 +1,18 @@
+package insight
+
+import (
+	"github.com/vedadiyan/goal/pkg/di"
+)
+
+func TraceResolutions(container *di.Container) {
+	container.Intercept(func(resolution di.Resolution, next func() (any, error)) (any, error) {
+		executionContext := New("di", resolution.Name)
+		executionContext.Start(resolution.Type, resolution.LifeCycle.String())
+		instance, err := next()
+		if err != nil {
+			executionContext.Error(err)
+		}
+		executionContext.Close()
+		return instance, err
+	})
+}
@@ -20,6 +20,7 @@ const (
 	WARN     LogLevels = "warn"
 	ERROR    LogLevels = "error"
 	CRITICAL LogLevels = "critical"
+	METRIC   LogLevels = "metric"
 )
 
 type IExecutionContext interface {
@@ -145,7 +146,9 @@ func (e *ExecutionContext) Close() {
 	e.end = time.Now()
 	info := make(map[string]any)
 	info["status"] = "Ended"
-	info["benchmark"] = e.end.Sub(e.start).Nanoseconds()
+	if !e.start.IsZero() {
+		info["benchmark"] = e.end.Sub(e.start).Nanoseconds()
+	}
 	e.logger(INFO, e.id, info)
 	for _, middleware := range _middleware {
 		middleware(e.id, e.origin, INFO, info)
@@ -156,6 +159,17 @@ func (e *ExecutionContext) OnFailure(fn func(err error)) {
 	e.fn = fn
 }
 
+func Measure(origin string, id string, fields map[string]any) {
+	_logger(METRIC, id, fields)
+	for _, middleware := range _middleware {
+		middleware(id, origin, METRIC, fields)
+	}
+}
+
+func UseMiddleware(middleware func(id string, origin string, logLevel LogLevels, fields map[string]any)) {
+	_middleware = append(_middleware, middleware)
+}
+
 func RegisterLogger(logger Logger) {
 	_logger = logger
 }
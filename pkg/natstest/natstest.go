@@ -0,0 +1,206 @@
+package natstest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
+	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/service"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	_TIMEOUT = time.Second * 5
+)
+
+// Server is an embedded nats-server with JetStream enabled whose connection is
+// registered in the default container under the given name so that services and
+// proxies resolve it as they do in production. Starting a server under a name that
+// is already registered refreshes it, which swaps and disposes the previous
+// connection.
+type Server struct {
+	t      testing.TB
+	server *server.Server
+	conn   *nats.Conn
+	name   string
+	codec  codecs.CompressedProtoConn
+}
+
+type Recorder struct {
+	t    testing.TB
+	subs *nats.Subscription
+}
+
+func Start(t testing.TB, connName string) *Server {
+	t.Helper()
+	server, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Start()
+	if !server.ReadyForConnections(_TIMEOUT) {
+		t.Fatalf("the embedded nats-server did not start within %s", _TIMEOUT)
+	}
+	t.Cleanup(server.Shutdown)
+	conn, err := nats.Connect(server.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(conn.Close)
+	if di.HasWithName(connName) {
+		_, err = di.RefreshSinletonWithName(connName, func(current *nats.Conn) (*nats.Conn, error) {
+			return conn, nil
+		})
+	} else {
+		err = di.AddSinletonWithName(connName, func() (*nats.Conn, error) {
+			return conn, nil
+		})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Server{
+		t:      t,
+		server: server,
+		conn:   conn,
+		name:   connName,
+	}
+}
+
+func (s *Server) Conn() *nats.Conn {
+	return s.conn
+}
+
+func (s *Server) Name() string {
+	return s.name
+}
+
+func (s *Server) ClientURL() string {
+	return s.server.ClientURL()
+}
+
+// Run configures and starts the service and shuts it down when the test ends. The
+// service is reloaded when its connection is refreshed, and once the test has ended
+// it only acknowledges reloads so that later refreshes of the name do not block.
+func (s *Server) Run(service service.Service) {
+	s.t.Helper()
+	service.Configure(false)
+	err := service.Start()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var mut sync.Mutex
+	stopped := false
+	go s.reload(service, &mut, &stopped)
+	s.t.Cleanup(func() {
+		mut.Lock()
+		defer mut.Unlock()
+		stopped = true
+		_ = service.Shutdown()
+	})
+}
+
+func (s *Server) reload(svc service.Service, mut *sync.Mutex, stopped *bool) {
+	reloadChan := svc.Reload()
+	for state := range reloadChan {
+		switch state {
+		case service.RELOADING:
+			mut.Lock()
+			if !*stopped {
+				_ = svc.Shutdown()
+			}
+			reloadChan <- service.READY
+		case service.RELOADED:
+			if !*stopped {
+				svc.Configure(true)
+				err := svc.Start()
+				if err != nil {
+					s.t.Error(err)
+				}
+			}
+			mut.Unlock()
+		}
+	}
+}
+
+// Record subscribes to the subject, for instance an on-success or on-failure
+// callback subject, and keeps the messages published to it.
+func (s *Server) Record(subject string) *Recorder {
+	s.t.Helper()
+	subs, err := s.conn.SubscribeSync(subject)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	err = s.conn.Flush()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return &Recorder{
+		t:    s.t,
+		subs: subs,
+	}
+}
+
+// Request sends the request to the subject and returns the raw response so that
+// its headers can be asserted on.
+func (s *Server) Request(subject string, request proto.Message, header nats.Header) *nats.Msg {
+	s.t.Helper()
+	data, err := s.codec.Encode(subject, request)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	service.StampSchema(msg.Header, request)
+	for key, values := range header {
+		msg.Header[key] = values
+	}
+	response, err := s.conn.RequestMsg(msg, _TIMEOUT)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return response
+}
+
+func (s *Server) Decode(msg *nats.Msg, response proto.Message) {
+	s.t.Helper()
+	err := service.DecodeWithSchema(&s.codec, msg, response)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func (r *Recorder) Next() *nats.Msg {
+	r.t.Helper()
+	msg, err := r.subs.NextMsg(_TIMEOUT)
+	if err != nil {
+		r.t.Fatalf("expected a message on %s: %v", r.subs.Subject, err)
+	}
+	return msg
+}
+
+func (r *Recorder) ExpectNone(wait time.Duration) {
+	r.t.Helper()
+	msg, err := r.subs.NextMsg(wait)
+	if err == nil {
+		r.t.Fatalf("expected no message on %s but got %v", r.subs.Subject, msg.Header)
+	}
+}
+
+func ExpectHeader(t testing.TB, msg *nats.Msg, key string, value string) {
+	t.Helper()
+	if actual := msg.Header.Get(key); actual != value {
+		t.Fatalf("expected the header %s to be `%s` but got `%s`", key, value, actual)
+	}
+}
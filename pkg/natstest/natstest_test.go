@@ -0,0 +1,230 @@
+package natstest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	"github.com/vedadiyan/goal/pkg/fault"
+	"github.com/vedadiyan/goal/pkg/health"
+	"github.com/vedadiyan/goal/pkg/proxy"
+	"github.com/vedadiyan/goal/pkg/service"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestService(t *testing.T) {
+	server := Start(t, "natstest_service")
+	var calls atomic.Int32
+	server.Run(service.New[*wrapperspb.StringValue, *wrapperspb.StringValue](server.Name(), "natstest.echo", "natstest", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		calls.Add(1)
+		if request.Value == "" {
+			return nil, fault.New(fault.INVALID_ARGUMENT, "empty value")
+		}
+		return wrapperspb.String(request.Value + "!"), nil
+	}, service.WithCache(time.Minute), service.WithOnSuccessCallBacks("natstest.succeeded"), service.WithOnFailureCallBacks("natstest.failed")))
+	succeeded := server.Record("natstest.succeeded")
+	failed := server.Record("natstest.failed")
+
+	echo := proxy.New(server.Name(), "natstest.echo", func() *wrapperspb.StringValue {
+		return &wrapperspb.StringValue{}
+	})
+	response, err := echo.Send(wrapperspb.String("goal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*response).Value != "goal!" {
+		t.Fatalf("unexpected response %s", (*response).Value)
+	}
+	ExpectHeader(t, succeeded.Next(), "status", "SUCCESS")
+
+	msg := server.Request("natstest.echo", wrapperspb.String("goal"), nil)
+	ExpectHeader(t, msg, service.CACHE_HEADER, service.CACHE_HIT)
+	cached := &wrapperspb.StringValue{}
+	server.Decode(msg, cached)
+	if cached.Value != "goal!" || calls.Load() != 1 {
+		t.Fatalf("expected the cached response without calling the handler again")
+	}
+
+	_, err = echo.Send(wrapperspb.String(""))
+	var invalid *fault.Error
+	if !errors.As(err, &invalid) || invalid.Code != fault.INVALID_ARGUMENT {
+		t.Fatalf("expected an invalid argument fault but got %v", err)
+	}
+	ExpectHeader(t, failed.Next(), fault.CODE_HEADER, string(fault.INVALID_ARGUMENT))
+	failed.ExpectNone(time.Millisecond * 100)
+}
+
+func TestMiddlewareBeforeCache(t *testing.T) {
+	server := Start(t, "natstest_middleware")
+	authorize := func(next service.Handler) service.Handler {
+		return func(ctx context.Context, request proto.Message) (proto.Message, error) {
+			if service.HeaderFromContext(ctx, "tenant") == "" {
+				return nil, fault.New(fault.UNAUTHENTICATED, "missing tenant")
+			}
+			return next(ctx, request)
+		}
+	}
+	server.Run(service.New[*wrapperspb.StringValue, *wrapperspb.StringValue](server.Name(), "natstest.guarded", "natstest", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	}, service.WithCache(time.Minute), service.WithMiddleware(authorize)))
+
+	ExpectHeader(t, server.Request("natstest.guarded", wrapperspb.String("goal"), nats.Header{"tenant": []string{"goal"}}), "status", "SUCCESS")
+	ExpectHeader(t, server.Request("natstest.guarded", wrapperspb.String("goal"), nats.Header{"tenant": []string{"goal"}}), service.CACHE_HEADER, service.CACHE_HIT)
+	ExpectHeader(t, server.Request("natstest.guarded", wrapperspb.String("goal"), nil), fault.CODE_HEADER, string(fault.UNAUTHENTICATED))
+}
+
+func TestSubscriber(t *testing.T) {
+	server := Start(t, "natstest_subscriber")
+	received := make(chan string, 4)
+	for i := 0; i < 2; i++ {
+		server.Run(service.NewSubscriber(server.Name(), "natstest.events", "", func(ctx context.Context, event *wrapperspb.StringValue) error {
+			received <- event.Value
+			return nil
+		}))
+	}
+	server.Run(service.NewSubscriber(server.Name(), "natstest.events", "failing", func(ctx context.Context, event *wrapperspb.StringValue) error {
+		return fault.New(fault.FAILED_PRECONDITION, "rejected")
+	}, service.WithOnFailureCallBacks("natstest.events.failed")))
+	failed := server.Record("natstest.events.failed")
+	server.Run(service.New[*wrapperspb.StringValue, *wrapperspb.StringValue](server.Name(), "natstest.publish", "natstest", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	}, service.WithOnSuccessCallBacks("natstest.events")))
+
+	server.Request("natstest.publish", wrapperspb.String("event"), nil)
+	for i := 0; i < 2; i++ {
+		select {
+		case value := <-received:
+			if value != "event" {
+				t.Fatalf("unexpected event %s", value)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("expected every fan-out subscriber to receive the event")
+		}
+	}
+	msg := failed.Next()
+	ExpectHeader(t, msg, "status", "FAIL:HANDLE")
+	ExpectHeader(t, msg, fault.CODE_HEADER, string(fault.FAILED_PRECONDITION))
+}
+
+func TestDiscovery(t *testing.T) {
+	server := Start(t, "natstest_discovery")
+	server.Run(service.New[*wrapperspb.StringValue, *wrapperspb.StringValue](server.Name(), "natstest.discovered", "natstest", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		if request.Value == "" {
+			return nil, fault.New(fault.INVALID_ARGUMENT, "empty value")
+		}
+		return request, nil
+	}, service.WithServiceInfo("discovered", "1.2.3", "discovery test")))
+	server.Request("natstest.discovered", wrapperspb.String("goal"), nil)
+	server.Request("natstest.discovered", wrapperspb.String(""), nil)
+
+	msg, err := server.Conn().Request("$SRV.INFO.discovered", nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := micro.Info{}
+	if err := json.Unmarshal(msg.Data, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Type != micro.InfoResponseType || info.Version != "1.2.3" || info.Metadata["request_type"] != "google.protobuf.StringValue" || info.Subjects[0] != "natstest.discovered" {
+		t.Fatalf("unexpected info %v", info)
+	}
+	msg, err = server.Conn().Request("$SRV.STATS.discovered."+info.ID, nil, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := micro.Stats{}
+	if err := json.Unmarshal(msg.Data, &stats); err != nil {
+		t.Fatal(err)
+	}
+	endpoint := stats.Endpoints[0]
+	if endpoint.NumRequests != 2 || endpoint.NumErrors != 1 || endpoint.AverageProcessingTime <= 0 {
+		t.Fatalf("unexpected stats %v", endpoint)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	server := Start(t, "natstest_health")
+	echo := service.New[*wrapperspb.StringValue, *wrapperspb.StringValue](server.Name(), "natstest.healthy", "natstest", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	})
+	server.Run(echo)
+	for name, check := range echo.Checks() {
+		if err := check(context.Background()); err != nil {
+			t.Fatalf("expected %s to be healthy: %v", name, err)
+		}
+	}
+	if err := health.JetStream(server.Conn(), "")(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := health.JetStream(server.Conn(), "missing")(context.Background()); err == nil {
+		t.Fatalf("expected a missing stream to be unhealthy")
+	}
+	conn, err := nats.Connect(server.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if err := health.NATS(conn)(context.Background()); err == nil {
+		t.Fatalf("expected a closed connection to be unhealthy")
+	}
+}
+
+func TestTwoTierCache(t *testing.T) {
+	server := Start(t, "natstest_two_tier")
+	tiered, err := service.TwoTierBackend(time.Minute)(server.Conn(), "natstest.two_tier", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = tiered.(io.Closer).Close()
+	})
+	remote, err := service.KVBackend()(server.Conn(), "natstest.two_tier", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tiered.Put("key", []byte("pending")); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Put("key", []byte("completed")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		value, err := tiered.Get("key")
+		if err == nil && string(value) == "completed" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a write by another instance to evict the local copy, got %s", value)
+		}
+		<-time.After(time.Millisecond * 10)
+	}
+}
+
+func TestRestartUnderSameName(t *testing.T) {
+	first := Start(t, "natstest_shared")
+	first.Run(service.New[*wrapperspb.StringValue, *wrapperspb.StringValue](first.Name(), "natstest.shared", "natstest", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	}))
+	ExpectHeader(t, first.Request("natstest.shared", wrapperspb.String("goal"), nil), "status", "SUCCESS")
+	second := Start(t, "natstest_shared")
+	if second.Name() != "natstest_shared" || !first.Conn().IsDraining() && !first.Conn().IsClosed() {
+		t.Fatalf("expected the second server to replace the connection of the first one")
+	}
+	deadline := time.Now().Add(_TIMEOUT)
+	for {
+		_, err := second.Conn().Request("natstest.shared", nil, _TIMEOUT)
+		if !errors.Is(err, nats.ErrNoResponders) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	ExpectHeader(t, second.Request("natstest.shared", wrapperspb.String("goal"), nil), "status", "SUCCESS")
+}
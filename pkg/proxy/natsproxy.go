@@ -1,12 +1,14 @@
 package proxy
 
 import (
-	"fmt"
+	"context"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
 	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/fault"
+	"github.com/vedadiyan/goal/pkg/wire"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -16,6 +18,7 @@ const (
 	_ENCODE_ERROR  ProxyError = ProxyError("encode error")
 	_DECODE_ERROR  ProxyError = ProxyError("decode error")
 	_GATEWAY_ERROR ProxyError = ProxyError("gateway error")
+	_SCHEMA_ERROR  ProxyError = ProxyError("schema error")
 )
 
 func (p ProxyError) Error() string {
@@ -23,60 +26,90 @@ func (p ProxyError) Error() string {
 }
 
 type NATSProxy[TResponse proto.Message] struct {
-	conn      *nats.Conn
+	conn      *di.Lazy[nats.Conn]
 	codec     codecs.CompressedProtoConn
 	namespace string
 	new       func() TResponse
 }
 
 func (p NATSProxy[TResponse]) Send(request proto.Message) (*TResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	return p.SendWithContext(ctx, request)
+}
+
+func (p NATSProxy[TResponse]) SendWithContext(ctx context.Context, request proto.Message) (*TResponse, error) {
 	enc, err := p.codec.Encode(p.namespace, request)
 	if err != nil {
 		return nil, _ENCODE_ERROR
 	}
-	msg, err := p.conn.Request(p.namespace, enc, time.Hour)
+	conn, err := p.conn.Get()
+	if err != nil {
+		return nil, _GATEWAY_ERROR
+	}
+	req := nats.NewMsg(p.namespace)
+	req.Data = enc
+	wire.StampSchema(req.Header, request)
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(wire.TIMEOUT_HEADER, timeoutOf(deadline))
+	}
+	msg, err := conn.RequestMsgWithContext(ctx, req)
 	if err != nil {
 		return nil, _GATEWAY_ERROR
 
 	}
-	status := msg.Header.Get("status")
-	if status != "SUCCESS" {
-		return nil, fmt.Errorf(`{"status": "%s", "message": "%s"}`, status, msg.Header.Get("error"))
+	err = statusOf(msg)
+	if err != nil {
+		return nil, err
 	}
 	res := p.new()
-	err = p.codec.Decode(p.namespace, msg.Data, res)
+	err = decode(&p.codec, msg, res)
 	if err != nil {
-		return nil, _DECODE_ERROR
+		return nil, err
 	}
 	return &res, nil
 }
+func statusOf(msg *nats.Msg) error {
+	if msg.Header.Get("status") != "SUCCESS" {
+		return fault.FromHeader(msg.Header)
+	}
+	return nil
+}
+
+func decode(codec *codecs.CompressedProtoConn, msg *nats.Msg, res proto.Message) error {
+	err := wire.DecodeWithSchema(codec, msg, res)
+	if _, ok := err.(*fault.Error); ok {
+		return _SCHEMA_ERROR
+	}
+	if err != nil {
+		return _DECODE_ERROR
+	}
+	return nil
+}
+
+func timeoutOf(deadline time.Time) string {
+	return time.Until(deadline).String()
+}
+
 func New[TResponse proto.Message](connName string, namespace string, newRes func() TResponse) *NATSProxy[TResponse] {
-	conn := di.ResolveWithNameOrPanic[nats.Conn](connName, nil)
 	natsProxy := NATSProxy[TResponse]{
 		namespace: namespace,
-		conn:      conn,
+		conn:      di.NewLazyWithName[nats.Conn](connName, nil),
 		codec:     codecs.CompressedProtoConn{},
 		new:       newRes,
 	}
-	di.OnRefreshWithName(connName, func(e di.Events) {
-		natsProxy.conn = di.ResolveWithNameOrPanic[nats.Conn](connName, nil)
-	})
 	return &natsProxy
 }
 
 func Create[TResponse any](connName string, namespace string) *NATSProxy[proto.Message] {
-	conn := di.ResolveWithNameOrPanic[nats.Conn](connName, nil)
 	natsProxy := NATSProxy[proto.Message]{
 		namespace: namespace,
-		conn:      conn,
+		conn:      di.NewLazyWithName[nats.Conn](connName, nil),
 		codec:     codecs.CompressedProtoConn{},
 		new: func() proto.Message {
 			var res TResponse
 			return any(&res).(proto.Message)
 		},
 	}
-	di.OnRefreshWithName(connName, func(e di.Events) {
-		natsProxy.conn = di.ResolveWithNameOrPanic[nats.Conn](connName, nil)
-	})
 	return &natsProxy
 }
@@ -0,0 +1,162 @@
+package proxy
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
+	"github.com/vedadiyan/goal/pkg/wire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	_SEQUENCE_ERROR ProxyError = ProxyError("sequence error")
+)
+
+// Stream iterates over the chunks of a streaming response. Chunks are acknowledged
+// every half window so that the service never sends more than the window ahead.
+type Stream[TResponse proto.Message] struct {
+	ctx       context.Context
+	conn      *nats.Conn
+	subs      *nats.Subscription
+	codec     codecs.CompressedProtoConn
+	namespace string
+	new       func() TResponse
+	window    uint64
+	seq       uint64
+	acked     uint64
+	ack       string
+	value     *TResponse
+	done      bool
+	err       error
+}
+
+func (p NATSProxy[TResponse]) Stream(ctx context.Context, request proto.Message) (*Stream[TResponse], error) {
+	return p.StreamWithWindow(ctx, request, wire.STREAM_WINDOW)
+}
+
+func (p NATSProxy[TResponse]) StreamWithWindow(ctx context.Context, request proto.Message, window uint64) (*Stream[TResponse], error) {
+	enc, err := p.codec.Encode(p.namespace, request)
+	if err != nil {
+		return nil, _ENCODE_ERROR
+	}
+	conn, err := p.conn.Get()
+	if err != nil {
+		return nil, _GATEWAY_ERROR
+	}
+	inbox := nats.NewInbox()
+	subs, err := conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, _GATEWAY_ERROR
+	}
+	req := nats.NewMsg(p.namespace)
+	req.Reply = inbox
+	req.Data = enc
+	wire.StampSchema(req.Header, request)
+	req.Header.Set(wire.STREAM_WINDOW_HEADER, strconv.FormatUint(window, 10))
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set(wire.TIMEOUT_HEADER, timeoutOf(deadline))
+	}
+	err = conn.PublishMsg(req)
+	if err != nil {
+		_ = subs.Unsubscribe()
+		return nil, _GATEWAY_ERROR
+	}
+	stream := &Stream[TResponse]{
+		ctx:       ctx,
+		conn:      conn,
+		subs:      subs,
+		codec:     p.codec,
+		namespace: p.namespace,
+		new:       p.new,
+		window:    window,
+	}
+	return stream, nil
+}
+
+func (s *Stream[TResponse]) Next() bool {
+	if s.done {
+		return false
+	}
+	msg, err := s.subs.NextMsgWithContext(s.ctx)
+	if err != nil {
+		return s.fail(_GATEWAY_ERROR)
+	}
+	if len(msg.Data) == 0 && msg.Header.Get("Status") == "503" {
+		return s.fail(_GATEWAY_ERROR)
+	}
+	err = statusOf(msg)
+	if err != nil {
+		return s.fail(err)
+	}
+	seq, err := strconv.ParseUint(msg.Header.Get(wire.STREAM_SEQ_HEADER), 10, 64)
+	if err != nil || seq != s.seq+1 {
+		return s.fail(_SEQUENCE_ERROR)
+	}
+	s.seq = seq
+	if msg.Header.Get(wire.STREAM_EOS_HEADER) != "" {
+		s.done = true
+		s.value = nil
+		_ = s.subs.Unsubscribe()
+		return false
+	}
+	s.ack = msg.Header.Get(wire.STREAM_ACK_HEADER)
+	res := s.new()
+	err = decode(&s.codec, msg, res)
+	if err != nil {
+		return s.fail(err)
+	}
+	s.value = &res
+	if s.seq-s.acked >= s.threshold() {
+		s.acknowledge(nil)
+	}
+	return true
+}
+
+func (s *Stream[TResponse]) Value() *TResponse {
+	return s.value
+}
+
+func (s *Stream[TResponse]) Err() error {
+	return s.err
+}
+
+// Close stops the iteration and asks the service to cancel the stream if it
+// has not ended yet.
+func (s *Stream[TResponse]) Close() error {
+	if s.done {
+		return nil
+	}
+	s.done = true
+	s.acknowledge(nats.Header{wire.STREAM_CANCEL_HEADER: []string{"true"}})
+	return s.subs.Unsubscribe()
+}
+
+func (s *Stream[TResponse]) threshold() uint64 {
+	if s.window < 2 {
+		return 1
+	}
+	return s.window / 2
+}
+
+func (s *Stream[TResponse]) acknowledge(header nats.Header) {
+	if s.ack == "" {
+		return
+	}
+	msg := nats.NewMsg(s.ack)
+	for key, values := range header {
+		msg.Header[key] = values
+	}
+	msg.Header.Set(wire.STREAM_SEQ_HEADER, strconv.FormatUint(s.seq, 10))
+	if s.conn.PublishMsg(msg) == nil {
+		s.acked = s.seq
+	}
+}
+
+func (s *Stream[TResponse]) fail(err error) bool {
+	s.err = err
+	s.value = nil
+	_ = s.Close()
+	return false
+}
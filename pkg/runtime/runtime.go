@@ -3,11 +3,13 @@ package runtime
 import (
 	"os"
 	"os/signal"
+	"syscall"
 )
 
 func WaitForInterrupt(callback func()) {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
+	signal.Stop(c)
 	callback()
 }
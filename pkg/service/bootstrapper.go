@@ -1,9 +1,13 @@
 package service
 
 import (
+	"context"
 	"log"
 	"sync"
+	"time"
 
+	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/health"
 	"github.com/vedadiyan/goal/pkg/runtime"
 )
 
@@ -19,6 +23,8 @@ const (
 var _services []any
 var _mute sync.Mutex
 var _skipInterrupt bool
+var _disposeTimeout = time.Second * 30
+var _gracePeriod = time.Second * 30
 
 type Service interface {
 	Configure(bool)
@@ -38,33 +44,51 @@ func Register(service Service) {
 }
 
 func Bootstrap() {
+	health.AddLiveness("bootstrap", _lifecycle.live)
+	health.AddReadiness("bootstrap", _lifecycle.ready)
 	services := make([]Service, 0)
-	for _, service := range _services {
-		services = append(services, service.(Service))
+	err := di.Build()
+	if err != nil {
+		_lifecycle.fail(err)
+	} else {
+		for _, service := range _services {
+			services = append(services, service.(Service))
+		}
 	}
 	for _, service := range services {
+		registerChecks(service)
 		starter(service)
 	}
 	if !_skipInterrupt {
 		runtime.WaitForInterrupt(func() {
-			for _, service := range services {
-				_ = service.Shutdown()
-
+			for i := len(services) - 1; i >= 0; i-- {
+				err := services[i].Shutdown()
+				if err != nil {
+					log.Println(err)
+				}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), _disposeTimeout)
+			defer cancel()
+			err := di.Shutdown(ctx)
+			if err != nil {
+				log.Println(err)
 			}
 		})
 	}
 }
 
 func starter(service Service) {
+	_lifecycle.begin()
 	log.Println("configuring")
 	service.Configure(false)
 	log.Println("configured")
 	log.Println("starting")
 	err := service.Start()
 	if err != nil {
-		log.Fatalln(err)
+		_lifecycle.fail(err)
 		return
 	}
+	_lifecycle.done()
 	log.Println("started")
 	go func(service Service) {
 		reloadChan := service.Reload()
@@ -73,11 +97,12 @@ func starter(service Service) {
 			switch value {
 			case RELOADING:
 				{
+					_lifecycle.begin()
 					log.Println("reloading")
 					err := service.Shutdown()
 					if err != nil {
 						reloadChan <- ERROR
-						log.Fatalln(err)
+						_lifecycle.fail(err)
 						return
 					}
 					reloadChan <- READY
@@ -91,9 +116,10 @@ func starter(service Service) {
 					log.Println("restarting")
 					err := service.Start()
 					if err != nil {
-						log.Fatalln(err)
+						_lifecycle.fail(err)
 						break LOOP
 					}
+					_lifecycle.done()
 					log.Println("restarted")
 				}
 			}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/fault"
+	"github.com/vedadiyan/goal/pkg/insight"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type CacheStates int
+
+const (
+	CACHE_HEADER = "cache"
+	CACHE_HIT    = "HIT"
+	CACHE_STALE  = "STALE"
+	CACHE_MISS   = "MISS"
+)
+
+const (
+	MISS CacheStates = iota
+	FRESH
+	STALE
+)
+
+// KeyFunc returns a segment of the cache key of a request. The segments of all the
+// key functions of a service are joined with dots so that the leading segments can
+// be used to invalidate a group of entries by prefix.
+type KeyFunc func(ctx context.Context, request proto.Message) (string, error)
+
+type CacheOption func(*cacheOptions)
+
+type cacheOptions struct {
+	backend   CacheBackend
+	keys      []KeyFunc
+	negative  map[fault.Codes]bool
+	errorTTL  time.Duration
+	staleTime time.Duration
+}
+
+type cacheEntry struct {
+	Data     []byte            `json:"data,omitempty"`
+	Header   map[string]string `json:"header,omitempty"`
+	StoredAt time.Time         `json:"storedAt"`
+	Error    bool              `json:"error,omitempty"`
+	Pending  bool              `json:"pending,omitempty"`
+}
+
+var (
+	_keySegment = regexp.MustCompile(`^[-_=a-zA-Z0-9]+$`)
+)
+
+func KeyFromRequest() KeyFunc {
+	return func(ctx context.Context, request proto.Message) (string, error) {
+		bytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(request)
+		if err != nil {
+			return "", err
+		}
+		return GetHash(bytes)
+	}
+}
+
+func KeyFromFields(fields ...string) KeyFunc {
+	return func(ctx context.Context, request proto.Message) (string, error) {
+		message := request.ProtoReflect()
+		segments := make([]string, 0, len(fields))
+		for _, field := range fields {
+			descriptor := message.Descriptor().Fields().ByName(protoreflect.Name(field))
+			if descriptor == nil || descriptor.IsList() || descriptor.IsMap() || descriptor.Message() != nil {
+				return "", invalidCacheFieldError(field)
+			}
+			segments = append(segments, fmt.Sprint(message.Get(descriptor).Interface()))
+		}
+		return CacheKey(segments...), nil
+	}
+}
+
+func KeyFromHeaders(headers ...string) KeyFunc {
+	return func(ctx context.Context, request proto.Message) (string, error) {
+		segments := make([]string, 0, len(headers))
+		for _, header := range headers {
+			segments = append(segments, HeaderFromContext(ctx, header))
+		}
+		return CacheKey(segments...), nil
+	}
+}
+
+// CacheKey joins the given values into a cache key, encoding the values that contain
+// characters that are not allowed in a key.
+func CacheKey(values ...string) string {
+	segments := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == "" {
+			segments = append(segments, "_")
+			continue
+		}
+		if _keySegment.MatchString(value) {
+			segments = append(segments, value)
+			continue
+		}
+		segments = append(segments, base64.RawURLEncoding.EncodeToString([]byte(value)))
+	}
+	return strings.Join(segments, ".")
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) cacheKey(ctx context.Context, request TReq) (string, error) {
+	keys := t.options.cache.keys
+	if len(keys) == 0 {
+		keys = []KeyFunc{KeyFromRequest()}
+	}
+	segments := make([]string, 0, len(keys))
+	for _, key := range keys {
+		segment, err := key(ctx, request)
+		if err != nil {
+			return "", err
+		}
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, "."), nil
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) lookup(key string) (*cacheEntry, CacheStates) {
+	value, err := t.cache.Get(key)
+	if err != nil {
+		return nil, MISS
+	}
+	entry := &cacheEntry{}
+	err = json.Unmarshal(value, entry)
+	if err != nil {
+		return nil, MISS
+	}
+	ttl := t.options.ttl
+	if entry.Error {
+		ttl = t.options.cache.errorTTL
+	}
+	age := time.Since(entry.StoredAt)
+	switch {
+	case age < ttl:
+		return entry, FRESH
+	case !entry.Error && age < ttl+t.options.cache.staleTime:
+		return entry, STALE
+	default:
+		return nil, MISS
+	}
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) store(key string, entry *cacheEntry) error {
+	entry.StoredAt = time.Now()
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return t.cache.Put(key, bytes)
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) replay(ctx *internal.NatsCtx, entry *cacheEntry, state string) {
+	headers := stamp(internal.Header{"status": "SUCCESS"}, t.newRes())
+	if entry.Error {
+		headers = internal.Header{}
+		for key, value := range entry.Header {
+			headers[key] = value
+		}
+	}
+	if state != "" {
+		headers[CACHE_HEADER] = state
+	}
+	if entry.Error {
+		ctx.Error(headers)
+		return
+	}
+	ctx.Success(entry.Data, headers)
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) isNegative(err error) bool {
+	if len(t.options.cache.negative) == 0 {
+		return false
+	}
+	var fe *fault.Error
+	return errors.As(err, &fe) && t.options.cache.negative[fe.Code]
+}
+
+// revalidate refreshes a stale entry in the background. Only one revalidation runs
+// per key at a time and it is not bound to the context of the request that found
+// the stale entry.
+func (t NATSService[TReq, TRes, TFuncType]) revalidate(key string, msg *nats.Msg, request TReq) {
+	if _, loaded := t.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	go func() {
+		defer t.revalidating.Delete(key)
+		insight := insight.New(t.namespace, key)
+		defer insight.Close()
+		msgCtx, cancel := newContext(t.ctx, msg, insight)
+		defer cancel()
+		scope := di.NewScope(msgCtx)
+		defer scope.Close()
+		response, err := t.invoke(scope.Context(), request)
+		if err != nil {
+			insight.Warn(err.Error())
+			return
+		}
+		bytes, err := t.codec.Encode(msg.Subject, response)
+		if err != nil {
+			insight.Warn(err.Error())
+			return
+		}
+		err = t.store(key, &cacheEntry{Data: bytes})
+		if err != nil {
+			insight.Warn(err.Error())
+		}
+	}()
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) Invalidate(key string) error {
+	if t.cache == nil {
+		return cacheNotConfiguredError(t.namespace)
+	}
+	return t.cache.Delete(key)
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) InvalidatePrefix(prefix string) error {
+	if t.cache == nil {
+		return cacheNotConfiguredError(t.namespace)
+	}
+	keys, err := t.cache.Keys()
+	if err != nil {
+		return err
+	}
+	errs := make([]error, 0)
+	for _, key := range keys {
+		if key != prefix && !strings.HasPrefix(key, prefix+".") {
+			continue
+		}
+		err := t.cache.Delete(key)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func WithCacheKey(keys ...KeyFunc) CacheOption {
+	return func(co *cacheOptions) {
+		co.keys = append(co.keys, keys...)
+	}
+}
+
+func WithNegativeCache(ttl time.Duration, codes ...fault.Codes) CacheOption {
+	return func(co *cacheOptions) {
+		co.errorTTL = ttl
+		if co.negative == nil {
+			co.negative = make(map[fault.Codes]bool)
+		}
+		for _, code := range codes {
+			co.negative[code] = true
+		}
+	}
+}
+
+func WithStaleWhileRevalidate(staleTime time.Duration) CacheOption {
+	return func(co *cacheOptions) {
+		co.staleTime = staleTime
+	}
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/cache"
+)
+
+// Cache stores the encoded responses of a service. Get returns cache.KEY_NOT_FOUND
+// when the key is missing, Add returns cache.DUPLICATE_KEY when the key exists and
+// Delete succeeds for keys that do not exist.
+type Cache interface {
+	Get(key string) ([]byte, error)
+	Add(key string, value []byte) error
+	Put(key string, value []byte) error
+	Delete(key string) error
+	Keys() ([]string, error)
+}
+
+type CacheBackend func(conn *nats.Conn, name string, ttl time.Duration) (Cache, error)
+
+type kvCache struct {
+	bucket nats.KeyValue
+}
+
+type localCache struct {
+	name string
+	ttl  time.Duration
+}
+
+type twoTierCache struct {
+	local   Cache
+	remote  Cache
+	watcher nats.KeyWatcher
+}
+
+func KVBackend() CacheBackend {
+	return func(conn *nats.Conn, name string, ttl time.Duration) (Cache, error) {
+		bucket, err := keyValueOf(conn, name, ttl)
+		if err != nil {
+			return nil, err
+		}
+		return &kvCache{bucket: bucket}, nil
+	}
+}
+
+func LocalBackend() CacheBackend {
+	return func(conn *nats.Conn, name string, ttl time.Duration) (Cache, error) {
+		return &localCache{name: name, ttl: ttl}, nil
+	}
+}
+
+// TwoTierBackend keeps hot keys in process for localTTL in front of the NATS KV
+// bucket. Keys written to or deleted from the bucket by any instance are evicted
+// locally as well.
+func TwoTierBackend(localTTL time.Duration) CacheBackend {
+	return func(conn *nats.Conn, name string, ttl time.Duration) (Cache, error) {
+		bucket, err := keyValueOf(conn, name, ttl)
+		if err != nil {
+			return nil, err
+		}
+		watcher, err := bucket.WatchAll(nats.MetaOnly())
+		if err != nil {
+			return nil, err
+		}
+		if localTTL <= 0 || localTTL > ttl {
+			localTTL = ttl
+		}
+		twoTier := &twoTierCache{
+			local:   &localCache{name: name, ttl: localTTL},
+			remote:  &kvCache{bucket: bucket},
+			watcher: watcher,
+		}
+		go twoTier.evict()
+		return twoTier, nil
+	}
+}
+
+func keyValueOf(conn *nats.Conn, name string, ttl time.Duration) (nats.KeyValue, error) {
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	bucketName := strings.ReplaceAll(name, ".", "_")
+	for bucket := range js.KeyValueStoreNames() {
+		if bucket == fmt.Sprintf("KV_%s", bucketName) {
+			return js.KeyValue(bucketName)
+		}
+	}
+	return js.CreateKeyValue(&nats.KeyValueConfig{
+		Bucket: bucketName,
+		TTL:    ttl,
+	})
+}
+
+func (c *kvCache) Get(key string) ([]byte, error) {
+	value, err := c.bucket.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil, cache.KEY_NOT_FOUND
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value.Value(), nil
+}
+
+func (c *kvCache) Add(key string, value []byte) error {
+	_, err := c.bucket.Create(key, value)
+	if errors.Is(err, nats.ErrKeyExists) {
+		return cache.DUPLICATE_KEY
+	}
+	return err
+}
+
+func (c *kvCache) Put(key string, value []byte) error {
+	_, err := c.bucket.Put(key, value)
+	return err
+}
+
+func (c *kvCache) Delete(key string) error {
+	err := c.bucket.Delete(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (c *kvCache) Keys() ([]string, error) {
+	keys, err := c.bucket.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return []string{}, nil
+	}
+	return keys, err
+}
+
+func (c *localCache) key(key string) string {
+	return fmt.Sprintf("%s/%s", c.name, key)
+}
+
+func (c *localCache) Get(key string) ([]byte, error) {
+	return cache.Get[[]byte](c.key(key))
+}
+
+func (c *localCache) Add(key string, value []byte) error {
+	return cache.AddWithTTL(c.key(key), value, c.ttl)
+}
+
+func (c *localCache) Put(key string, value []byte) error {
+	return cache.SetWithTTL(c.key(key), value, c.ttl)
+}
+
+func (c *localCache) Delete(key string) error {
+	err := cache.Delete(c.key(key))
+	if errors.Is(err, cache.KEY_NOT_FOUND) {
+		return nil
+	}
+	return err
+}
+
+func (c *localCache) Keys() ([]string, error) {
+	prefix := c.key("")
+	keys := make([]string, 0)
+	for _, key := range cache.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, strings.TrimPrefix(key, prefix))
+		}
+	}
+	return keys, nil
+}
+
+func (c *twoTierCache) Get(key string) ([]byte, error) {
+	value, err := c.local.Get(key)
+	if err == nil {
+		return value, nil
+	}
+	value, err = c.remote.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	_ = c.local.Put(key, value)
+	return value, nil
+}
+
+func (c *twoTierCache) Add(key string, value []byte) error {
+	return c.remote.Add(key, value)
+}
+
+func (c *twoTierCache) Put(key string, value []byte) error {
+	err := c.remote.Put(key, value)
+	if err != nil {
+		return err
+	}
+	return c.local.Put(key, value)
+}
+
+func (c *twoTierCache) Delete(key string) error {
+	return errors.Join(c.remote.Delete(key), c.local.Delete(key))
+}
+
+func (c *twoTierCache) Keys() ([]string, error) {
+	return c.remote.Keys()
+}
+
+func (c *twoTierCache) Close() error {
+	return c.watcher.Stop()
+}
+
+func (c *twoTierCache) evict() {
+	for entry := range c.watcher.Updates() {
+		if entry == nil {
+			continue
+		}
+		_ = c.local.Delete(entry.Key())
+	}
+}
+
+func WithCacheBackend(backend CacheBackend) CacheOption {
+	return func(co *cacheOptions) {
+		co.backend = backend
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/insight"
+	"github.com/vedadiyan/goal/pkg/wire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	TIMEOUT_HEADER = wire.TIMEOUT_HEADER
+)
+
+type messageKey struct{}
+
+type ContextHandler[TReq proto.Message, TRes proto.Message] func(ctx context.Context, request TReq) (TRes, error)
+
+type Message struct {
+	Subject        string
+	Reply          string
+	Header         nats.Header
+	ResponseHeader nats.Header
+	Insight        insight.IExecutionContext
+}
+
+func MessageFromContext(ctx context.Context) (*Message, bool) {
+	message, ok := ctx.Value(messageKey{}).(*Message)
+	return message, ok
+}
+
+func HeaderFromContext(ctx context.Context, key string) string {
+	message, ok := MessageFromContext(ctx)
+	if !ok || message.Header == nil {
+		return ""
+	}
+	return message.Header.Get(key)
+}
+
+func newContext(parent context.Context, msg *nats.Msg, insight insight.IExecutionContext) (context.Context, context.CancelFunc) {
+	message := &Message{
+		Subject:        msg.Subject,
+		Reply:          msg.Reply,
+		Header:         msg.Header,
+		ResponseHeader: nats.Header{},
+		Insight:        insight,
+	}
+	ctx := context.WithValue(parent, messageKey{}, message)
+	timeout := msg.Header.Get(TIMEOUT_HEADER)
+	if timeout == "" {
+		return context.WithCancel(ctx)
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil || duration <= 0 {
+		insight.Warn(invalidTimeoutError(timeout))
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, duration)
+}
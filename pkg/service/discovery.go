@@ -0,0 +1,204 @@
+package service
+
+import (
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+	"github.com/nats-io/nuid"
+	"github.com/vedadiyan/goal/pkg/insight"
+)
+
+// discovery answers the $SRV.PING, $SRV.INFO and $SRV.STATS subjects of the NATS
+// micro services protocol on behalf of a service.
+type discovery struct {
+	id          string
+	name        string
+	version     string
+	description string
+	subject     string
+	metadata    map[string]string
+	started     time.Time
+	baseline    counters
+	subs        []*nats.Subscription
+}
+
+type counters struct {
+	requests   int
+	errors     int
+	lastError  string
+	processing time.Duration
+}
+
+type endpointStats struct {
+	mute sync.Mutex
+	counters
+}
+
+const (
+	_DEFAULT_VERSION = "0.0.0"
+)
+
+var (
+	_stats       sync.Map
+	_invalidName = regexp.MustCompile(`[^A-Za-z0-9\-_]`)
+)
+
+// init aggregates the executions that insight reports for every origin, which is
+// the namespace of a service or the subject of a subscriber. A discovery reports
+// them relative to the moment its service started.
+func init() {
+	insight.UseMiddleware(collect)
+}
+
+func collect(id string, origin string, logLevel insight.LogLevels, fields map[string]any) {
+	switch {
+	case logLevel == insight.ERROR:
+		stats := statsOf(origin)
+		stats.mute.Lock()
+		defer stats.mute.Unlock()
+		stats.errors++
+		stats.lastError, _ = fields["error"].(string)
+	case logLevel == insight.INFO && fields["status"] == "Ended":
+		stats := statsOf(origin)
+		stats.mute.Lock()
+		defer stats.mute.Unlock()
+		stats.requests++
+		if benchmark, ok := fields["benchmark"].(int64); ok {
+			stats.processing += time.Duration(benchmark)
+		}
+	}
+}
+
+func statsOf(origin string) *endpointStats {
+	value, _ := _stats.LoadOrStore(origin, &endpointStats{})
+	return value.(*endpointStats)
+}
+
+func (s *endpointStats) load() counters {
+	s.mute.Lock()
+	defer s.mute.Unlock()
+	return s.counters
+}
+
+func newDiscovery(subject string, options NATSServiceOptions, metadata map[string]string) *discovery {
+	discovery := &discovery{
+		id:          nuid.Next(),
+		name:        options.name,
+		version:     options.version,
+		description: options.description,
+		subject:     subject,
+		metadata:    metadata,
+	}
+	if discovery.name == "" {
+		discovery.name = _invalidName.ReplaceAllString(subject, "_")
+	}
+	if discovery.version == "" {
+		discovery.version = _DEFAULT_VERSION
+	}
+	return discovery
+}
+
+func (d *discovery) start(conn *nats.Conn) error {
+	d.started = time.Now().UTC()
+	d.baseline = statsOf(d.subject).load()
+	for _, verb := range []micro.Verb{micro.PingVerb, micro.InfoVerb, micro.StatsVerb} {
+		for _, identity := range [][]string{{"", ""}, {d.name, ""}, {d.name, d.id}} {
+			subject, err := micro.ControlSubject(verb, identity[0], identity[1])
+			if err != nil {
+				d.stop()
+				return err
+			}
+			verb := verb
+			subs, err := conn.Subscribe(subject, func(msg *nats.Msg) {
+				d.respond(verb, msg)
+			})
+			if err != nil {
+				d.stop()
+				return err
+			}
+			d.subs = append(d.subs, subs)
+		}
+	}
+	return nil
+}
+
+func (d *discovery) stop() {
+	for _, subs := range d.subs {
+		_ = subs.Unsubscribe()
+	}
+	d.subs = nil
+}
+
+func (d *discovery) identity() micro.ServiceIdentity {
+	return micro.ServiceIdentity{
+		Name:     d.name,
+		ID:       d.id,
+		Version:  d.version,
+		Metadata: d.metadata,
+	}
+}
+
+func (d *discovery) respond(verb micro.Verb, msg *nats.Msg) {
+	var response any
+	switch verb {
+	case micro.PingVerb:
+		response = micro.Ping{
+			ServiceIdentity: d.identity(),
+			Type:            micro.PingResponseType,
+		}
+	case micro.InfoVerb:
+		response = micro.Info{
+			ServiceIdentity: d.identity(),
+			Type:            micro.InfoResponseType,
+			Description:     d.description,
+			Subjects:        []string{d.subject},
+		}
+	case micro.StatsVerb:
+		response = micro.Stats{
+			ServiceIdentity: d.identity(),
+			Type:            micro.StatsResponseType,
+			Started:         d.started,
+			Endpoints:       []*micro.EndpointStats{d.endpointStats()},
+		}
+	}
+	bytes, err := json.Marshal(response)
+	if err != nil {
+		insight.New(d.subject, msg.Subject).Error(err)
+		return
+	}
+	err = msg.Respond(bytes)
+	if err != nil {
+		insight.New(d.subject, msg.Subject).Error(err)
+	}
+}
+
+func (d *discovery) endpointStats() *micro.EndpointStats {
+	current := statsOf(d.subject).load()
+	endpoint := &micro.EndpointStats{
+		Name:           d.name,
+		Subject:        d.subject,
+		Metadata:       d.metadata,
+		NumRequests:    current.requests - d.baseline.requests,
+		NumErrors:      current.errors - d.baseline.errors,
+		ProcessingTime: current.processing - d.baseline.processing,
+	}
+	if endpoint.NumErrors > 0 {
+		endpoint.LastError = current.lastError
+	}
+	if endpoint.NumRequests > 0 {
+		endpoint.AverageProcessingTime = endpoint.ProcessingTime / time.Duration(endpoint.NumRequests)
+	}
+	return endpoint
+}
+
+func WithServiceInfo(name string, version string, description string) Option {
+	return func(no *NATSServiceOptions) {
+		no.name = name
+		no.version = version
+		no.description = description
+	}
+}
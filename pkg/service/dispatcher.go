@@ -0,0 +1,273 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/insight"
+)
+
+type OverflowPolicies int
+
+const (
+	REJECT OverflowPolicies = iota
+	BLOCK
+	DROP_OLDEST
+)
+
+type dispatcher struct {
+	namespace   string
+	handle      func(msg *nats.Msg)
+	reject      func(msg *nats.Msg)
+	policy      OverflowPolicies
+	workers     int
+	maxInFlight int
+	queue       chan *nats.Msg
+	semaphore   chan struct{}
+	done        chan struct{}
+	wg          sync.WaitGroup
+	pending     sync.WaitGroup
+	inFlight    atomic.Int64
+	dispatched  atomic.Uint64
+	rejected    atomic.Uint64
+	dropped     atomic.Uint64
+}
+
+const (
+	_METRICS_INTERVAL = time.Second * 10
+)
+
+func newDispatcher(namespace string, options NATSServiceOptions, handle func(msg *nats.Msg), reject func(msg *nats.Msg)) *dispatcher {
+	dispatcher := &dispatcher{
+		namespace:   namespace,
+		handle:      handle,
+		reject:      reject,
+		policy:      options.overflowPolicy,
+		workers:     options.workers,
+		maxInFlight: options.maxInFlight,
+		done:        make(chan struct{}),
+	}
+	if dispatcher.isBounded() {
+		dispatcher.queue = make(chan *nats.Msg, options.queueSize)
+	}
+	return dispatcher
+}
+
+func validateDispatcher(namespace string, options NATSServiceOptions) error {
+	if options.overflowPolicy != REJECT && options.queueSize <= 0 {
+		return pendingQueueRequiredError(namespace)
+	}
+	return nil
+}
+
+func (d *dispatcher) isBounded() bool {
+	return d.workers > 0 || d.maxInFlight > 0
+}
+
+// isDirect tells a dispatcher bounded by maxInFlight without a pending queue, which
+// takes a slot of the semaphore when a message arrives instead of queueing it.
+func (d *dispatcher) isDirect() bool {
+	return d.workers == 0 && cap(d.queue) == 0
+}
+
+func (d *dispatcher) start() {
+	if !d.isBounded() {
+		return
+	}
+	if d.workers > 0 {
+		for i := 0; i < d.workers; i++ {
+			d.wg.Add(1)
+			go d.work()
+		}
+	} else {
+		d.semaphore = make(chan struct{}, d.maxInFlight)
+		if !d.isDirect() {
+			d.wg.Add(1)
+			go d.pump()
+		}
+	}
+	go d.measure()
+}
+
+func (d *dispatcher) stop() {
+	select {
+	case <-d.done:
+		return
+	default:
+		close(d.done)
+	}
+}
+
+// drain waits up to the grace period for every accepted message to be handled
+// before stopping the dispatcher. Messages still queued after the grace period
+// are rejected and false is returned.
+func (d *dispatcher) drain(gracePeriod time.Duration) bool {
+	drained := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(drained)
+	}()
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		d.stop()
+		return true
+	case <-timer.C:
+		d.stop()
+		for {
+			select {
+			case msg := <-d.queue:
+				d.rejectMsg(msg)
+			default:
+				return false
+			}
+		}
+	}
+}
+
+func (d *dispatcher) dispatch(msg *nats.Msg) {
+	d.dispatched.Add(1)
+	d.pending.Add(1)
+	if !d.isBounded() {
+		go d.run(msg)
+		return
+	}
+	if d.isDirect() {
+		select {
+		case <-d.done:
+			d.rejectMsg(msg)
+		case d.semaphore <- struct{}{}:
+			go d.release(msg)
+		default:
+			d.rejectMsg(msg)
+		}
+		return
+	}
+	select {
+	case <-d.done:
+		d.rejectMsg(msg)
+		return
+	case d.queue <- msg:
+		return
+	default:
+	}
+	switch d.policy {
+	case BLOCK:
+		{
+			select {
+			case d.queue <- msg:
+			case <-d.done:
+				d.rejectMsg(msg)
+			}
+		}
+	case DROP_OLDEST:
+		{
+			for {
+				select {
+				case d.queue <- msg:
+					return
+				case <-d.done:
+					d.rejectMsg(msg)
+					return
+				default:
+				}
+				select {
+				case oldest := <-d.queue:
+					d.dropped.Add(1)
+					d.reject(oldest)
+					d.pending.Done()
+				default:
+				}
+			}
+		}
+	default:
+		{
+			d.rejectMsg(msg)
+		}
+	}
+}
+
+func (d *dispatcher) rejectMsg(msg *nats.Msg) {
+	defer d.pending.Done()
+	d.rejected.Add(1)
+	d.reject(msg)
+}
+
+func (d *dispatcher) run(msg *nats.Msg) {
+	defer d.pending.Done()
+	d.inFlight.Add(1)
+	defer d.inFlight.Add(-1)
+	d.handle(msg)
+}
+
+func (d *dispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case msg := <-d.queue:
+			d.run(msg)
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// pump takes a slot of the semaphore before it takes a message off the queue so
+// that no more than maxInFlight messages are ever held outside of the queue.
+func (d *dispatcher) pump() {
+	defer d.wg.Done()
+	for {
+		select {
+		case d.semaphore <- struct{}{}:
+		case <-d.done:
+			return
+		}
+		select {
+		case msg := <-d.queue:
+			go d.release(msg)
+		case <-d.done:
+			<-d.semaphore
+			return
+		}
+	}
+}
+
+// release runs a message that holds a slot of the semaphore and frees the slot.
+func (d *dispatcher) release(msg *nats.Msg) {
+	defer func() {
+		<-d.semaphore
+	}()
+	d.run(msg)
+}
+
+func (d *dispatcher) measure() {
+	ticker := time.NewTicker(_METRICS_INTERVAL)
+	defer ticker.Stop()
+	var last uint64
+	for {
+		select {
+		case <-ticker.C:
+			dispatched := d.dispatched.Load()
+			if dispatched == last {
+				continue
+			}
+			last = dispatched
+			d.report()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+func (d *dispatcher) report() {
+	insight.Measure(d.namespace, "dispatcher", map[string]any{
+		"queue_depth": len(d.queue),
+		"in_flight":   d.inFlight.Load(),
+		"dispatched":  d.dispatched.Load(),
+		"rejected":    d.rejected.Load(),
+		"dropped":     d.dropped.Load(),
+	})
+}
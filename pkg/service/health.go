@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/health"
+)
+
+type HealthChecker interface {
+	Checks() map[string]health.Check
+}
+
+type lifecycle struct {
+	mut     sync.RWMutex
+	pending int
+	err     error
+}
+
+var _lifecycle lifecycle
+
+func (l *lifecycle) begin() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.pending++
+}
+
+func (l *lifecycle) done() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.pending--
+}
+
+func (l *lifecycle) fail(err error) {
+	if !health.Exposed() {
+		log.Fatalln(err)
+	}
+	log.Println(err)
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.err = errors.Join(l.err, err)
+}
+
+func (l *lifecycle) live(ctx context.Context) error {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	return l.err
+}
+
+func (l *lifecycle) ready(ctx context.Context) error {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	if l.pending != 0 {
+		return notReadyError(l.pending)
+	}
+	return nil
+}
+
+func registerChecks(service Service) {
+	checker, ok := service.(HealthChecker)
+	if !ok {
+		return
+	}
+	for name, check := range checker.Checks() {
+		health.AddReadiness(name, check)
+	}
+}
+
+func connCheck(conn func() *nats.Conn) health.Check {
+	return func(ctx context.Context) error {
+		return health.NATS(conn())(ctx)
+	}
+}
+
+func streamCheck(conn func() *nats.Conn, stream string) health.Check {
+	return func(ctx context.Context) error {
+		return health.JetStream(conn(), stream)(ctx)
+	}
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/vedadiyan/goal/pkg/cache"
+	"github.com/vedadiyan/goal/pkg/fault"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+)
+
+const (
+	IDEMPOTENCY_KEY_HEADER = "idempotency-key"
+	_IDEMPOTENCY_LEASE     = time.Minute
+	_IDEMPOTENCY_POLL      = time.Millisecond * 100
+)
+
+type idempotentCall struct {
+	done  chan struct{}
+	entry *cacheEntry
+}
+
+func (t *NATSService[TReq, TRes, TFuncType]) configureIdempotency() error {
+	if t.options.idempotencyTTL <= 0 {
+		return invalidIdempotencyTTLError(t.namespace, t.options.idempotencyTTL)
+	}
+	backend := t.options.idempotencyBackend
+	if backend == nil {
+		backend = KVBackend()
+	}
+	store, err := backend(t.conn, t.namespace+".idempotency", t.options.idempotencyTTL)
+	if err != nil {
+		return err
+	}
+	// Records are claimed and completed by every instance, so a local copy of a
+	// pending record must never hide the completed one.
+	if twoTier, ok := store.(*twoTierCache); ok {
+		err := twoTier.Close()
+		if err != nil {
+			return err
+		}
+		store = twoTier.remote
+	}
+	t.idempotency = store
+	return nil
+}
+
+// acquire coalesces the requests that share an idempotency key. Duplicates handled by
+// this instance wait for the first execution, duplicates of an execution running on
+// another instance poll the store, and completed executions are replayed. When acquire
+// returns a release function the caller owns the execution and must call it once the
+// response has been sent.
+func (t NATSService[TReq, TRes, TFuncType]) acquire(ctx *internal.NatsCtx, key string) (release func(), replayed bool) {
+	call := &idempotentCall{done: make(chan struct{})}
+	if value, loaded := t.inflight.LoadOrStore(key, call); loaded {
+		existing := value.(*idempotentCall)
+		select {
+		case <-existing.done:
+			t.replayIdempotent(ctx, existing.entry)
+		case <-ctx.Context().Done():
+			ctx.Error(internal.Header{"status": "FAIL:IDEMPOTENCY"}, ctx.Context().Err())
+		}
+		return nil, true
+	}
+	finish := func(entry *cacheEntry) {
+		call.entry = entry
+		close(call.done)
+		t.inflight.Delete(key)
+	}
+	for {
+		entry, err := t.claim(key)
+		if err != nil {
+			ctx.Insight().Error(err)
+			ctx.Error(internal.Header{"status": "FAIL:IDEMPOTENCY"}, err)
+			finish(nil)
+			return nil, true
+		}
+		if entry == nil {
+			return func() {
+				finish(t.complete(ctx, key))
+			}, false
+		}
+		if !entry.Pending {
+			t.replay(ctx, entry, "")
+			finish(entry)
+			return nil, true
+		}
+		select {
+		case <-time.After(_IDEMPOTENCY_POLL):
+		case <-ctx.Context().Done():
+			ctx.Error(internal.Header{"status": "FAIL:IDEMPOTENCY"}, ctx.Context().Err())
+			finish(nil)
+			return nil, true
+		}
+	}
+}
+
+// claim records a pending execution for the key and returns nil when it succeeds.
+// Otherwise it returns the recorded entry, taking over pending executions whose
+// lease has expired.
+func (t NATSService[TReq, TRes, TFuncType]) claim(key string) (*cacheEntry, error) {
+	pending, err := json.Marshal(&cacheEntry{Pending: true, StoredAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	err = t.idempotency.Add(key, pending)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, cache.DUPLICATE_KEY) {
+		return nil, err
+	}
+	value, err := t.idempotency.Get(key)
+	if errors.Is(err, cache.KEY_NOT_FOUND) {
+		return t.claim(key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{}
+	err = json.Unmarshal(value, entry)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Pending && time.Since(entry.StoredAt) > _IDEMPOTENCY_LEASE {
+		return nil, t.idempotency.Put(key, pending)
+	}
+	return entry, nil
+}
+
+// complete records the response of the execution so that later duplicates replay
+// it. Executions that did not respond or failed with a retryable error are forgotten
+// so that a retry runs the handler again.
+func (t NATSService[TReq, TRes, TFuncType]) complete(ctx *internal.NatsCtx, key string) *cacheEntry {
+	headers := ctx.Headers()
+	if headers == nil || headers[fault.RETRYABLE_HEADER] == "true" {
+		err := t.idempotency.Delete(key)
+		if err != nil {
+			ctx.Insight().Warn(err.Error())
+		}
+		return nil
+	}
+	entry := &cacheEntry{
+		Data:     ctx.Data(),
+		Header:   headers,
+		StoredAt: time.Now(),
+		Error:    headers["status"] != "SUCCESS",
+	}
+	bytes, err := json.Marshal(entry)
+	if err == nil {
+		err = t.idempotency.Put(key, bytes)
+	}
+	if err != nil {
+		ctx.Insight().Warn(err.Error())
+	}
+	return entry
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) replayIdempotent(ctx *internal.NatsCtx, entry *cacheEntry) {
+	if entry == nil {
+		ctx.Error(internal.Header{"status": "FAIL:IDEMPOTENCY"}, fault.New(fault.UNAVAILABLE, "the original request did not complete").WithRetryable(true))
+		return
+	}
+	t.replay(ctx, entry, "")
+}
+
+func idempotencyKeyOf(ctx context.Context) string {
+	key := HeaderFromContext(ctx, IDEMPOTENCY_KEY_HEADER)
+	if key == "" {
+		return ""
+	}
+	return CacheKey(key)
+}
+
+func WithIdempotency(ttl time.Duration, backend CacheBackend) Option {
+	return func(no *NATSServiceOptions) {
+		no.isIdempotent = true
+		no.idempotencyTTL = ttl
+		no.idempotencyBackend = backend
+	}
+}
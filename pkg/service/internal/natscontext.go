@@ -1,23 +1,32 @@
 package natsCtx
 
 import (
+	"context"
+
 	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/fault"
 	"github.com/vedadiyan/goal/pkg/insight"
 )
 
 type Header map[string]string
 
 type NatsCtx struct {
+	ctx         context.Context
 	conn        *nats.Conn
 	insight     insight.IExecutionContext
 	requestMsg  *nats.Msg
 	responseMsg *nats.Msg
 	onsuccess   []string
 	onerror     []string
+	noReply     bool
+	headers     Header
+	data        []byte
+	extra       nats.Header
 }
 
-func NewNatsCtx(conn *nats.Conn, insight insight.IExecutionContext, msg *nats.Msg, onerror []string, onsuccess []string) *NatsCtx {
+func NewNatsCtx(ctx context.Context, conn *nats.Conn, insight insight.IExecutionContext, msg *nats.Msg, onerror []string, onsuccess []string) *NatsCtx {
 	return &NatsCtx{
+		ctx:         ctx,
 		conn:        conn,
 		insight:     insight,
 		requestMsg:  msg,
@@ -27,16 +36,66 @@ func NewNatsCtx(conn *nats.Conn, insight insight.IExecutionContext, msg *nats.Ms
 	}
 }
 
-func (nc *NatsCtx) Error(headers Header) {
-	msg := &nats.Msg{}
-	msg.Header = nats.Header{}
-	for key, value := range headers {
-		msg.Header.Add(key, value)
+func NewEventCtx(ctx context.Context, conn *nats.Conn, insight insight.IExecutionContext, msg *nats.Msg, onerror []string, onsuccess []string) *NatsCtx {
+	natsCtx := NewNatsCtx(ctx, conn, insight, msg, onerror, onsuccess)
+	natsCtx.noReply = true
+	return natsCtx
+}
+
+func (nc *NatsCtx) Context() context.Context {
+	return nc.ctx
+}
+
+// WithHeader adds the given header to every response. The header is read when the
+// response is sent so it can still be modified by the handler.
+func (nc *NatsCtx) WithHeader(header nats.Header) {
+	nc.extra = header
+}
+
+func (nc *NatsCtx) Insight() insight.IExecutionContext {
+	return nc.insight
+}
+
+func (nc *NatsCtx) Headers() Header {
+	return nc.headers
+}
+
+func (nc *NatsCtx) Data() []byte {
+	return nc.data
+}
+
+func (nc *NatsCtx) Status() string {
+	return nc.headers["status"]
+}
+
+func (nc *NatsCtx) respond(msg *nats.Msg) {
+	if nc.noReply {
+		return
 	}
 	err := nc.requestMsg.RespondMsg(msg)
 	if err != nil {
 		nc.insight.Error(err)
 	}
+}
+
+// Error responds with the given headers and, when an error is given, with the
+// headers that encode it as a fault.Error.
+func (nc *NatsCtx) Error(headers Header, err ...error) {
+	if len(err) != 0 && err[0] != nil {
+		for key, value := range fault.From(err[0]).Header() {
+			headers[key] = value
+		}
+	}
+	nc.headers = headers
+	msg := &nats.Msg{}
+	msg.Header = nats.Header{}
+	for key, values := range nc.extra {
+		msg.Header[key] = values
+	}
+	for key, value := range headers {
+		msg.Header.Add(key, value)
+	}
+	nc.respond(msg)
 	if nc.onerror == nil {
 		return
 	}
@@ -53,16 +112,18 @@ func (nc *NatsCtx) Error(headers Header) {
 
 }
 func (nc *NatsCtx) Success(data []byte, headers Header) {
+	nc.headers = headers
+	nc.data = data
 	msg := &nats.Msg{}
 	msg.Header = nats.Header{}
+	for key, values := range nc.extra {
+		msg.Header[key] = values
+	}
 	for key, value := range headers {
 		msg.Header.Add(key, value)
 	}
 	msg.Data = data
-	err := nc.requestMsg.RespondMsg(msg)
-	if err != nil {
-		nc.insight.Error(err)
-	}
+	nc.respond(msg)
 	if nc.onsuccess == nil {
 		return
 	}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/vedadiyan/goal/pkg/insight"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+)
+
+type ConsumerModes int
+
+const (
+	PUSH ConsumerModes = iota
+	PULL
+)
+
+const (
+	_FETCH_BATCH   = 10
+	_FETCH_TIMEOUT = time.Second * 5
+)
+
+var (
+	_poisonStatuses = map[string]bool{
+		"FAIL:DECODE":       true,
+		"FAIL:ENCODE":       true,
+		"FAIL:REQUEST:HASH": true,
+		"FAIL:SCHEMA":       true,
+	}
+)
+
+func (t *NATSService[TReq, TRes, TFuncType]) configureConsumer(js nats.JetStreamContext) error {
+	config := &nats.ConsumerConfig{
+		Durable:       t.options.durable,
+		FilterSubject: t.namespace,
+		AckPolicy:     nats.AckExplicitPolicy,
+		MaxDeliver:    t.options.maxDeliver,
+		BackOff:       t.options.backoff,
+	}
+	info, err := js.ConsumerInfo(t.options.stream, t.options.durable)
+	if err != nil && !errors.Is(err, nats.ErrConsumerNotFound) {
+		return err
+	}
+	if t.options.consumerMode == PUSH {
+		config.DeliverGroup = t.queue
+		config.DeliverSubject = nats.NewInbox()
+		if info != nil {
+			config.DeliverSubject = info.Config.DeliverSubject
+		}
+	}
+	if info == nil {
+		_, err = js.AddConsumer(t.options.stream, config)
+		return err
+	}
+	_, err = js.UpdateConsumer(t.options.stream, config)
+	return err
+}
+
+// subscribeJetStream binds to a durable consumer that is created or updated up front
+// so that unsubscribing on shutdown or reload never deletes the consumer.
+func (t *NATSService[TReq, TRes, TFuncType]) subscribeJetStream() (*nats.Subscription, error) {
+	js, err := t.conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	err = t.configureConsumer(js)
+	if err != nil {
+		return nil, err
+	}
+	bind := nats.Bind(t.options.stream, t.options.durable)
+	if t.options.consumerMode == PUSH {
+		return js.QueueSubscribe(t.namespace, t.queue, t.dispatcher.dispatch, bind, nats.ManualAck())
+	}
+	subs, err := js.PullSubscribe(t.namespace, t.options.durable, bind)
+	if err != nil {
+		return nil, err
+	}
+	go t.fetch(t.ctx, subs)
+	return subs, nil
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) fetch(ctx context.Context, subs *nats.Subscription) {
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, _FETCH_TIMEOUT)
+		msgs, err := subs.Fetch(_FETCH_BATCH, nats.Context(fetchCtx))
+		cancel()
+		for _, msg := range msgs {
+			t.dispatcher.dispatch(msg)
+		}
+		if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+			continue
+		}
+		if ctx.Err() != nil || !subs.IsValid() {
+			return
+		}
+		insight.New(t.namespace, t.options.durable).Warn(err.Error())
+		select {
+		case <-time.After(_FETCH_TIMEOUT):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) consume(msg *nats.Msg) {
+	ctx := t.handle(msg, internal.NewEventCtx)
+	status := ctx.Status()
+	if status == "SUCCESS" {
+		t.settle(msg, msg.Ack())
+		return
+	}
+	metadata, err := msg.Metadata()
+	if err != nil {
+		t.settle(msg, err)
+		return
+	}
+	if !_poisonStatuses[status] && (t.options.maxDeliver <= 0 || metadata.NumDelivered < uint64(t.options.maxDeliver)) {
+		t.settle(msg, msg.NakWithDelay(redeliveryDelay(t.options.backoff, metadata.NumDelivered)))
+		return
+	}
+	t.deadLetter(msg, ctx.Headers(), metadata)
+	t.settle(msg, msg.Term())
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) redeliver(msg *nats.Msg) {
+	t.settle(msg, msg.Nak())
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) settle(msg *nats.Msg, err error) {
+	if err != nil {
+		insight.New(t.namespace, msg.Reply).Error(err)
+	}
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) deadLetter(msg *nats.Msg, headers internal.Header, metadata *nats.MsgMetadata) {
+	if t.options.deadLetter == "" {
+		return
+	}
+	deadLetter := nats.NewMsg(t.options.deadLetter)
+	deadLetter.Data = msg.Data
+	for key, values := range msg.Header {
+		deadLetter.Header[key] = values
+	}
+	for key, value := range headers {
+		deadLetter.Header.Set(key, value)
+	}
+	deadLetter.Header.Set("subject", msg.Subject)
+	deadLetter.Header.Set("stream", metadata.Stream)
+	deadLetter.Header.Set("consumer", metadata.Consumer)
+	deadLetter.Header.Set("deliveries", fmt.Sprintf("%d", metadata.NumDelivered))
+	err := t.conn.PublishMsg(deadLetter)
+	if err != nil {
+		insight.New(t.namespace, msg.Reply).Error(err)
+	}
+}
+
+func redeliveryDelay(backoff []time.Duration, deliveries uint64) time.Duration {
+	if len(backoff) == 0 || deliveries == 0 {
+		return 0
+	}
+	if deliveries > uint64(len(backoff)) {
+		return backoff[len(backoff)-1]
+	}
+	return backoff[deliveries-1]
+}
+
+func WithJetStream(stream string, durable string, mode ConsumerModes) Option {
+	return func(no *NATSServiceOptions) {
+		no.isJetStream = true
+		no.stream = stream
+		no.durable = durable
+		no.consumerMode = mode
+	}
+}
+
+func WithMaxDeliver(maxDeliver int) Option {
+	return func(no *NATSServiceOptions) {
+		no.maxDeliver = maxDeliver
+	}
+}
+
+func WithRedeliveryBackoff(backoff ...time.Duration) Option {
+	return func(no *NATSServiceOptions) {
+		no.backoff = backoff
+	}
+}
+
+func WithDeadLetter(subject string) Option {
+	return func(no *NATSServiceOptions) {
+		no.deadLetter = subject
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+type Handler func(ctx context.Context, request proto.Message) (proto.Message, error)
+
+// Middleware wraps the typed handler of a service. The request headers are available
+// through MessageFromContext and response headers can be added with SetResponseHeader.
+type Middleware func(next Handler) Handler
+
+var _middleware []Middleware
+var _middlewareMute sync.Mutex
+
+func Use(middleware ...Middleware) {
+	_middlewareMute.Lock()
+	defer _middlewareMute.Unlock()
+	_middleware = append(_middleware, middleware...)
+}
+
+func SetResponseHeader(ctx context.Context, key string, value string) bool {
+	message, ok := MessageFromContext(ctx)
+	if !ok {
+		return false
+	}
+	message.ResponseHeader.Set(key, value)
+	return true
+}
+
+// chain composes the global middleware followed by the middleware of the service
+// so that the first registered middleware is the outermost one.
+func chain(handler Handler, middleware []Middleware) Handler {
+	_middlewareMute.Lock()
+	all := make([]Middleware, 0, len(_middleware)+len(middleware))
+	all = append(all, _middleware...)
+	_middlewareMute.Unlock()
+	all = append(all, middleware...)
+	for i := len(all) - 1; i >= 0; i-- {
+		handler = all[i](handler)
+	}
+	return handler
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) invoke(ctx context.Context, request TReq) (response TRes, err error) {
+	return t.intercept(ctx, request, t.handlerFn)
+}
+
+// intercept runs the middleware chain around fn, which may answer the request on
+// its own, for instance from the cache, after the middleware has let it through.
+func (t NATSService[TReq, TRes, TFuncType]) intercept(ctx context.Context, request TReq, fn func(ctx context.Context, request TReq) (TRes, error)) (response TRes, err error) {
+	handler := chain(func(ctx context.Context, request proto.Message) (proto.Message, error) {
+		req, ok := request.(TReq)
+		if !ok {
+			return nil, invalidMessageError(request)
+		}
+		return fn(ctx, req)
+	}, t.options.middleware)
+	res, err := handler(ctx, request)
+	if err != nil {
+		return response, err
+	}
+	response, ok := res.(TRes)
+	if !ok {
+		return response, invalidMessageError(res)
+	}
+	return response, nil
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) invokeStream(ctx context.Context, request TReq, stream *Stream[TRes]) error {
+	handler := chain(func(ctx context.Context, request proto.Message) (proto.Message, error) {
+		req, ok := request.(TReq)
+		if !ok {
+			return nil, invalidMessageError(request)
+		}
+		return nil, t.streamFn(ctx, req, stream)
+	}, t.options.middleware)
+	_, err := handler(ctx, request)
+	return err
+}
+
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(no *NATSServiceOptions) {
+		no.middleware = append(no.middleware, middleware...)
+	}
+}
@@ -1,16 +1,20 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
-	"fmt"
+	"errors"
+	"io"
 	"reflect"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
 	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/fault"
+	"github.com/vedadiyan/goal/pkg/health"
 	"github.com/vedadiyan/goal/pkg/insight"
 	internal "github.com/vedadiyan/goal/pkg/service/internal"
 	"google.golang.org/protobuf/proto"
@@ -18,23 +22,53 @@ import (
 
 type Option func(*NATSServiceOptions)
 
+type natsCtxFactory func(ctx context.Context, conn *nats.Conn, insight insight.IExecutionContext, msg *nats.Msg, onerror []string, onsuccess []string) *internal.NatsCtx
+
 type NATSServiceOptions struct {
-	isCached  bool
-	ttl       time.Duration
-	onsuccess []string
-	onerror   []string
+	isCached           bool
+	ttl                time.Duration
+	cache              cacheOptions
+	onsuccess          []string
+	onerror            []string
+	workers            int
+	maxInFlight        int
+	queueSize          int
+	overflowPolicy     OverflowPolicies
+	gracePeriod        time.Duration
+	isJetStream        bool
+	stream             string
+	durable            string
+	consumerMode       ConsumerModes
+	maxDeliver         int
+	backoff            []time.Duration
+	deadLetter         string
+	middleware         []Middleware
+	isIdempotent       bool
+	idempotencyTTL     time.Duration
+	idempotencyBackend CacheBackend
+	name               string
+	version            string
+	description        string
 }
 
-type NATSService[TReq proto.Message, TRes proto.Message, TFuncType ~func(TReq) (TRes, error)] struct {
+type NATSService[TReq proto.Message, TRes proto.Message, TFuncType ~func(TReq) (TRes, error) | ~func(context.Context, TReq) (TRes, error) | ~func(context.Context, TReq, *Stream[TRes]) error] struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
 	conn         *nats.Conn
 	codec        *codecs.CompressedProtoConn
 	reloadState  chan ReloadStates
 	subscription *nats.Subscription
-	bucket       *nats.KeyValue
+	dispatcher   *dispatcher
+	discovery    *discovery
+	cache        Cache
+	idempotency  Cache
+	inflight     *sync.Map
+	revalidating *sync.Map
 	connName     string
 	namespace    string
 	queue        string
-	handlerFn    TFuncType
+	handlerFn    func(ctx context.Context, request TReq) (TRes, error)
+	streamFn     func(ctx context.Context, request TReq, stream *Stream[TRes]) error
 	options      NATSServiceOptions
 	newReq       func() TReq
 	newRes       func() TRes
@@ -53,101 +87,183 @@ func (t *NATSService[TReq, TRes, TFuncType]) Configure(b bool) {
 	t.conn = di.ResolveWithNameOrPanic[nats.Conn](t.connName, nil)
 }
 func (t *NATSService[TReq, TRes, TFuncType]) configureCache() error {
-	js, err := t.conn.JetStream()
-	if err != nil {
-		return err
+	backend := t.options.cache.backend
+	if backend == nil {
+		backend = KVBackend()
 	}
-	buckets := js.KeyValueStoreNames()
-	bucketExists := false
-	bucketName := strings.ReplaceAll(t.namespace, ".", "_")
-	for bucket := range buckets {
-		if bucket == fmt.Sprintf("KV_%s", bucketName) {
-			bucketExists = true
-			break
-		}
-	}
-	if !bucketExists {
-		bucket, err := js.CreateKeyValue(&nats.KeyValueConfig{
-			Bucket: bucketName,
-			TTL:    t.options.ttl,
-		})
-		if err != nil {
-			return err
-		}
-		t.bucket = &bucket
-		return nil
-	}
-	bucket, err := js.KeyValue(bucketName)
+	cache, err := backend(t.conn, t.namespace, t.options.ttl+t.options.cache.staleTime)
 	if err != nil {
 		return err
 	}
-	t.bucket = &bucket
+	t.cache = cache
 	return nil
 }
 func (t *NATSService[TReq, TRes, TFuncType]) Start() error {
+	err := validateDispatcher(t.namespace, t.options)
+	if err != nil {
+		return err
+	}
+	if t.streamFn != nil && t.options.isJetStream {
+		return streamOverJetStreamError(t.namespace)
+	}
 	if t.options.isCached {
 		err := t.configureCache()
 		if err != nil {
 			return err
 		}
 	}
+	if t.options.isIdempotent {
+		err := t.configureIdempotency()
+		if err != nil {
+			return err
+		}
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.dispatcher = newDispatcher(t.namespace, t.options, t.handler, t.busy)
+	t.dispatcher.start()
 	var subs *nats.Subscription
-	var err error
-	subs, err = t.conn.QueueSubscribe(t.namespace, t.queue, func(msg *nats.Msg) {
-		go t.handler(msg)
-	})
+	if t.options.isJetStream {
+		subs, err = t.subscribeJetStream()
+	} else {
+		subs, err = t.conn.QueueSubscribe(t.namespace, t.queue, t.dispatcher.dispatch)
+	}
 	if err != nil {
+		t.dispatcher.stop()
+		t.cancel()
 		return err
 	}
 	t.subscription = subs
-	return nil
+	t.discovery = newDiscovery(t.namespace, t.options, map[string]string{
+		"queue":         t.queue,
+		"request_type":  fullNameOf(t.newReq()),
+		"response_type": fullNameOf(t.newRes()),
+	})
+	return t.discovery.start(t.conn)
 }
 func (t NATSService[TReq, TRes, TFuncType]) Shutdown() error {
-	if t.conn.IsDraining() || t.conn.IsClosed() {
-		return nil
+	if t.cancel != nil {
+		defer t.cancel()
+	}
+	var err error
+	if t.discovery != nil {
+		t.discovery.stop()
+	}
+	if t.subscription != nil && !t.conn.IsDraining() && !t.conn.IsClosed() {
+		err = t.subscription.Unsubscribe()
+	}
+	if t.dispatcher != nil && !t.dispatcher.drain(t.options.gracePeriod) {
+		insight.New(t.namespace, "shutdown").Warn(gracePeriodExceededError(t.namespace, t.options.gracePeriod).Error())
 	}
-	return t.subscription.Unsubscribe()
+	if closer, ok := t.cache.(io.Closer); ok {
+		return errors.Join(err, closer.Close())
+	}
+	return err
 }
 func (t NATSService[TReq, TRes, TFuncType]) Reload() chan ReloadStates {
 	return t.reloadState
 }
 
+func (t *NATSService[TReq, TRes, TFuncType]) Checks() map[string]health.Check {
+	conn := func() *nats.Conn {
+		return t.conn
+	}
+	checks := map[string]health.Check{
+		"nats." + t.namespace: connCheck(conn),
+	}
+	if t.options.isJetStream {
+		checks["jetstream."+t.namespace] = streamCheck(conn, t.options.stream)
+	}
+	return checks
+}
+
 func (t NATSService[TReq, TRes, TFuncType]) handler(msg *nats.Msg) {
-	var requestHash string
+	if t.options.isJetStream {
+		t.consume(msg)
+		return
+	}
+	t.handle(msg, internal.NewNatsCtx)
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) handle(msg *nats.Msg, newCtx natsCtxFactory) (ctx *internal.NatsCtx) {
+	var cacheKey string
 	insight := insight.New(t.namespace, msg.Reply)
 	defer insight.Close()
-	ctx := internal.NewNatsCtx(t.conn, insight, msg, t.options.onerror, t.options.onsuccess)
+	msgCtx, cancel := newContext(t.ctx, msg, insight)
+	defer cancel()
+	scope := di.NewScope(msgCtx)
+	defer scope.Close()
+	ctx = newCtx(scope.Context(), t.conn, insight, msg, t.options.onerror, t.options.onsuccess)
+	if message, ok := MessageFromContext(msgCtx); ok {
+		ctx.WithHeader(message.ResponseHeader)
+	}
 	request := t.newReq()
 	insight.OnFailure(func(err error) {
-		ctx.Error(internal.Header{"status": "FAIL:RECOVERED", "error": err.Error()})
+		ctx.Error(internal.Header{"status": "FAIL:RECOVERED"}, err)
 	})
 	if len(msg.Data) > 0 {
-		err := t.codec.Decode(msg.Subject, msg.Data, request)
+		err := DecodeWithSchema(t.codec, msg, request)
 		if err != nil {
 			insight.Error(err)
-			ctx.Error(internal.Header{"status": "FAIL:DECODE"})
+			status, err := decodeStatusOf(err)
+			ctx.Error(internal.Header{"status": status}, err)
 			return
 		}
 	}
 	insight.Start(request)
-	if t.options.isCached {
-		_requestHash, err := GetHash(msg.Data)
-		if err != nil {
-			insight.Error(err)
-			ctx.Error(internal.Header{"status": "FAIL:REQUEST:HASH"})
-			return
+	if t.streamFn != nil {
+		t.stream(ctx, msg, request)
+		return
+	}
+	var release func()
+	defer func() {
+		if release != nil {
+			release()
 		}
-		requestHash = _requestHash
-		value, err := (*t.bucket).Get(requestHash)
-		if err == nil {
-			ctx.Success(value.Value(), internal.Header{"status": "SUCCESS"})
-			return
+	}()
+	replied := false
+	response, err := t.intercept(ctx.Context(), request, func(c context.Context, request TReq) (response TRes, err error) {
+		if key := idempotencyKeyOf(c); t.options.isIdempotent && key != "" {
+			release, replied = t.acquire(ctx, key)
+			if replied {
+				return response, nil
+			}
 		}
+		if t.options.isCached {
+			key, err := t.cacheKey(c, request)
+			if err != nil {
+				insight.Error(err)
+				ctx.Error(internal.Header{"status": "FAIL:REQUEST:HASH"})
+				replied = true
+				return response, nil
+			}
+			cacheKey = key
+			entry, state := t.lookup(cacheKey)
+			switch state {
+			case FRESH:
+				t.replay(ctx, entry, CACHE_HIT)
+				replied = true
+				return response, nil
+			case STALE:
+				t.revalidate(cacheKey, msg, request)
+				t.replay(ctx, entry, CACHE_STALE)
+				replied = true
+				return response, nil
+			}
+		}
+		return t.handlerFn(c, request)
+	})
+	if replied {
+		return
 	}
-	response, err := t.handlerFn(request)
 	if err != nil {
 		insight.Error(err)
-		ctx.Error(internal.Header{"status": "FAIL:HANDLE", "error": strings.ReplaceAll(err.Error(), "\"", "\\\"")})
+		ctx.Error(internal.Header{"status": "FAIL:HANDLE"}, err)
+		if cacheKey != "" && t.isNegative(err) {
+			err = t.store(cacheKey, &cacheEntry{Header: ctx.Headers(), Error: true})
+			if err != nil {
+				insight.Warn(err)
+			}
+		}
 		return
 	}
 	bytes, err := t.codec.Encode(msg.Subject, response)
@@ -156,13 +272,29 @@ func (t NATSService[TReq, TRes, TFuncType]) handler(msg *nats.Msg) {
 		ctx.Error(internal.Header{"status": "FAIL:ENCODE"})
 		return
 	}
+	headers := stamp(internal.Header{"status": "SUCCESS"}, response)
 	if t.options.isCached {
-		_, err = (*t.bucket).Create(requestHash, bytes)
+		err = t.store(cacheKey, &cacheEntry{Data: bytes})
 		if err != nil {
 			insight.Warn(err)
 		}
+		headers[CACHE_HEADER] = CACHE_MISS
 	}
-	ctx.Success(bytes, internal.Header{"status": "SUCCESS"})
+	ctx.Success(bytes, headers)
+	return
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) busy(msg *nats.Msg) {
+	if t.options.isJetStream {
+		t.redeliver(msg)
+		return
+	}
+	if msg.Reply == "" {
+		return
+	}
+	insight := insight.New(t.namespace, msg.Reply)
+	ctx := internal.NewNatsCtx(t.ctx, t.conn, insight, msg, nil, nil)
+	ctx.Error(internal.Header{"status": "FAIL:BUSY"}, fault.New(fault.RESOURCE_EXHAUSTED, "the service is busy").WithRetryable(true))
 }
 
 func GetHash(bytes []byte) (string, error) {
@@ -175,15 +307,22 @@ func GetHash(bytes []byte) (string, error) {
 	return base64.URLEncoding.EncodeToString(requestHash), nil
 }
 
-func New[TReq proto.Message, TRes proto.Message, TFuncType ~func(TReq) (TRes, error)](connName string, namespace string, queue string, handlerFn TFuncType, options ...Option) *NATSService[TReq, TRes, TFuncType] {
+func New[TReq proto.Message, TRes proto.Message, TFuncType ~func(TReq) (TRes, error) | ~func(context.Context, TReq) (TRes, error)](connName string, namespace string, queue string, handlerFn TFuncType, options ...Option) *NATSService[TReq, TRes, TFuncType] {
+	service := newService[TReq, TRes, TFuncType](connName, namespace, queue, options...)
+	service.handlerFn = handlerOf[TReq, TRes](handlerFn)
+	return service
+}
+
+func newService[TReq proto.Message, TRes proto.Message, TFuncType ~func(TReq) (TRes, error) | ~func(context.Context, TReq) (TRes, error) | ~func(context.Context, TReq, *Stream[TRes]) error](connName string, namespace string, queue string, options ...Option) *NATSService[TReq, TRes, TFuncType] {
 	tReq := reflect.TypeOf(*new(TReq)).Elem()
 	tRes := reflect.TypeOf(*new(TRes)).Elem()
 	service := NATSService[TReq, TRes, TFuncType]{
-		namespace:   namespace,
-		queue:       queue,
-		handlerFn:   handlerFn,
-		connName:    connName,
-		reloadState: make(chan ReloadStates),
+		namespace:    namespace,
+		queue:        queue,
+		connName:     connName,
+		reloadState:  make(chan ReloadStates),
+		revalidating: &sync.Map{},
+		inflight:     &sync.Map{},
 		newReq: func() TReq {
 			return reflect.New(tReq).Interface().(TReq)
 		},
@@ -192,16 +331,58 @@ func New[TReq proto.Message, TRes proto.Message, TFuncType ~func(TReq) (TRes, er
 		},
 		codec: &codecs.CompressedProtoConn{},
 	}
+	service.options.gracePeriod = _gracePeriod
 	for _, option := range options {
 		option(&service.options)
 	}
 	return &service
 }
 
-func WithCache(ttl time.Duration) Option {
+func handlerOf[TReq proto.Message, TRes proto.Message](handlerFn any) func(ctx context.Context, request TReq) (TRes, error) {
+	var handler func(request TReq) (TRes, error)
+	value := reflect.ValueOf(handlerFn)
+	if value.Type().ConvertibleTo(reflect.TypeOf(handler)) {
+		handler = value.Convert(reflect.TypeOf(handler)).Interface().(func(request TReq) (TRes, error))
+		return func(ctx context.Context, request TReq) (TRes, error) {
+			return handler(request)
+		}
+	}
+	var contextHandler func(ctx context.Context, request TReq) (TRes, error)
+	return value.Convert(reflect.TypeOf(contextHandler)).Interface().(func(ctx context.Context, request TReq) (TRes, error))
+}
+
+func WithCache(ttl time.Duration, options ...CacheOption) Option {
 	return func(n *NATSServiceOptions) {
 		n.isCached = true
 		n.ttl = ttl
+		for _, option := range options {
+			option(&n.cache)
+		}
+	}
+}
+
+func WithMaxInFlight(max int) Option {
+	return func(no *NATSServiceOptions) {
+		no.maxInFlight = max
+	}
+}
+
+func WithWorkerPool(workers int) Option {
+	return func(no *NATSServiceOptions) {
+		no.workers = workers
+	}
+}
+
+func WithPendingQueue(size int, policy OverflowPolicies) Option {
+	return func(no *NATSServiceOptions) {
+		no.queueSize = size
+		no.overflowPolicy = policy
+	}
+}
+
+func WithGracePeriod(gracePeriod time.Duration) Option {
+	return func(no *NATSServiceOptions) {
+		no.gracePeriod = gracePeriod
 	}
 }
 
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"github.com/nats-io/nats.go"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
+	"github.com/vedadiyan/goal/pkg/fault"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+	"github.com/vedadiyan/goal/pkg/wire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	SCHEMA_TYPE_HEADER    = wire.SCHEMA_TYPE_HEADER
+	SCHEMA_VERSION_HEADER = wire.SCHEMA_VERSION_HEADER
+	ENCODING_HEADER       = wire.ENCODING_HEADER
+	ENCODING              = wire.ENCODING
+)
+
+func RegisterSchemaVersion[T proto.Message](version string) {
+	wire.RegisterSchemaVersion[T](version)
+}
+
+func RegisterAdapter[TOld proto.Message, TNew proto.Message](version string, adapt func(old TOld) (TNew, error)) {
+	wire.RegisterAdapter(version, adapt)
+}
+
+func StampSchema(header nats.Header, message proto.Message) {
+	wire.StampSchema(header, message)
+}
+
+func DecodeWithSchema(codec *codecs.CompressedProtoConn, msg *nats.Msg, target proto.Message) error {
+	return wire.DecodeWithSchema(codec, msg, target)
+}
+
+func stamp(header internal.Header, message proto.Message) internal.Header {
+	for key, value := range wire.SchemaOf(message) {
+		header[key] = value
+	}
+	return header
+}
+
+// decodeStatusOf tells schema errors, which are faults, apart from codec errors.
+func decodeStatusOf(err error) (string, error) {
+	if fe, ok := err.(*fault.Error); ok {
+		return "FAIL:SCHEMA", fe
+	}
+	return "FAIL:DECODE", fault.New(fault.INVALID_ARGUMENT, err.Error())
+}
+
+func fullNameOf(message proto.Message) string {
+	return wire.FullNameOf(message)
+}
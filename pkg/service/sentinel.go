@@ -0,0 +1,42 @@
+package service
+
+import (
+	"fmt"
+	"time"
+)
+
+func invalidTimeoutError(timeout string) error {
+	return fmt.Errorf("the timeout header `%s` is not a valid duration", timeout)
+}
+
+func gracePeriodExceededError(namespace string, gracePeriod time.Duration) error {
+	return fmt.Errorf("`%s` did not drain within the grace period of %s", namespace, gracePeriod)
+}
+
+func pendingQueueRequiredError(namespace string) error {
+	return fmt.Errorf("`%s` cannot block or drop the oldest message without a pending queue", namespace)
+}
+
+func invalidMessageError(message any) error {
+	return fmt.Errorf("unexpected message of type %T", message)
+}
+
+func invalidCacheFieldError(field string) error {
+	return fmt.Errorf("`%s` is not a scalar field and cannot be used as a cache key", field)
+}
+
+func cacheNotConfiguredError(namespace string) error {
+	return fmt.Errorf("`%s` is not cached", namespace)
+}
+
+func notReadyError(pending int) error {
+	return fmt.Errorf("%d service(s) are still starting or reloading", pending)
+}
+
+func streamOverJetStreamError(namespace string) error {
+	return fmt.Errorf("`%s` streams its response to the reply inbox and cannot consume from JetStream", namespace)
+}
+
+func invalidIdempotencyTTLError(namespace string, ttl time.Duration) error {
+	return fmt.Errorf("`%s` cannot keep idempotency records for %s", namespace, ttl)
+}
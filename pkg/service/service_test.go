@@ -1,11 +1,21 @@
 package service
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
 	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/insight"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 // func TestService(t *testing.T) {
@@ -38,17 +48,388 @@ import (
 func TestMap(t *testing.T) {
 	i := 0
 	x := &i
-	di.AddSinleton(func() (instance *int, err error) {
+	container := di.NewContainer()
+	err := di.For[int](container).AddSinleton(func() (instance *int, err error) {
 		return x, nil
 	})
-	go func() {
-		value := di.ResolveOrPanic[*int](nil)
-		for {
-			fmt.Println(**value)
-			<-time.After(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := di.For[int](container).ResolveOrPanic(nil)
+	i = 10
+	if value != x || *value != 10 {
+		t.Fatalf("expected the singleton to share the registered pointer")
+	}
+}
+
+func TestContext(t *testing.T) {
+	msg := nats.NewMsg("test")
+	msg.Reply = "_INBOX.test"
+	msg.Header.Set(TIMEOUT_HEADER, "1s")
+	msg.Header.Set("tenant", "goal")
+	ctx, cancel := newContext(context.Background(), msg, insight.New("test", msg.Reply))
+	defer cancel()
+	if _, ok := ctx.Deadline(); !ok {
+		t.Fatalf("expected the timeout header to set a deadline")
+	}
+	message, ok := MessageFromContext(ctx)
+	if !ok || message.Reply != "_INBOX.test" || HeaderFromContext(ctx, "tenant") != "goal" {
+		t.Fatalf("unexpected message %v", message)
+	}
+	handler := handlerOf[*wrapperspb.StringValue, *wrapperspb.StringValue](func(ctx context.Context, request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String(HeaderFromContext(ctx, "tenant") + request.Value), nil
+	})
+	response, err := handler(ctx, wrapperspb.String("!"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Value != "goal!" {
+		t.Fatalf("unexpected response %s", response.Value)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	rejected := make(chan *nats.Msg, 2)
+	handle := func(msg *nats.Msg) {
+		started <- struct{}{}
+		<-release
+	}
+	reject := func(msg *nats.Msg) {
+		rejected <- msg
+	}
+	dispatcher := newDispatcher("test", NATSServiceOptions{workers: 1, queueSize: 1, overflowPolicy: REJECT}, handle, reject)
+	dispatcher.start()
+	defer dispatcher.stop()
+	dispatcher.dispatch(nats.NewMsg("1"))
+	<-started
+	dispatcher.dispatch(nats.NewMsg("2"))
+	dispatcher.dispatch(nats.NewMsg("3"))
+	select {
+	case msg := <-rejected:
+		if msg.Subject != "3" {
+			t.Fatalf("expected the third message to be rejected but got %s", msg.Subject)
 		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the third message to be rejected")
+	}
+	close(release)
+	<-started
+	if dispatcher.rejected.Load() != 1 || dispatcher.dispatched.Load() != 3 {
+		t.Fatalf("unexpected counters %d %d", dispatcher.rejected.Load(), dispatcher.dispatched.Load())
+	}
+}
+
+func TestDispatcherMaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	rejected := make(chan *nats.Msg, 2)
+	handle := func(msg *nats.Msg) {
+		started <- struct{}{}
+		<-release
+	}
+	reject := func(msg *nats.Msg) {
+		rejected <- msg
+	}
+	if validateDispatcher("test", NATSServiceOptions{maxInFlight: 1, overflowPolicy: DROP_OLDEST}) == nil {
+		t.Fatalf("expected dropping the oldest message without a pending queue to be rejected")
+	}
+	dispatcher := newDispatcher("test", NATSServiceOptions{maxInFlight: 1, queueSize: 1, overflowPolicy: REJECT}, handle, reject)
+	dispatcher.start()
+	defer dispatcher.stop()
+	dispatcher.dispatch(nats.NewMsg("1"))
+	<-started
+	dispatcher.dispatch(nats.NewMsg("2"))
+	dispatcher.dispatch(nats.NewMsg("3"))
+	select {
+	case msg := <-rejected:
+		if msg.Subject != "3" {
+			t.Fatalf("expected the third message to be rejected but got %s", msg.Subject)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the third message to be rejected while the second one waits in the queue")
+	}
+	close(release)
+	<-started
+}
+
+func TestDispatcherMaxInFlightWithoutQueue(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 5)
+	handle := func(msg *nats.Msg) {
+		started <- struct{}{}
+		<-release
+	}
+	dispatcher := newDispatcher("test", NATSServiceOptions{maxInFlight: 10}, handle, func(msg *nats.Msg) {})
+	dispatcher.start()
+	defer dispatcher.stop()
+	for i := 0; i < 5; i++ {
+		dispatcher.dispatch(nats.NewMsg(fmt.Sprint(i)))
+	}
+	for i := 0; i < 5; i++ {
+		<-started
+	}
+	if dispatcher.rejected.Load() != 0 || dispatcher.inFlight.Load() != 5 {
+		t.Fatalf("expected every message to run while slots are free, %d were rejected", dispatcher.rejected.Load())
+	}
+	close(release)
+}
+
+func TestDispatcherDrain(t *testing.T) {
+	release := make(chan struct{})
+	handled := make(chan struct{}, 2)
+	handle := func(msg *nats.Msg) {
+		<-release
+		handled <- struct{}{}
+	}
+	dispatcher := newDispatcher("test", NATSServiceOptions{}, handle, func(msg *nats.Msg) {})
+	dispatcher.start()
+	dispatcher.dispatch(nats.NewMsg("1"))
+	if dispatcher.drain(time.Millisecond * 50) {
+		t.Fatalf("expected the drain to exceed the grace period")
+	}
+	close(release)
+	<-handled
+	dispatcher = newDispatcher("test", NATSServiceOptions{workers: 1, queueSize: 1}, handle, func(msg *nats.Msg) {})
+	dispatcher.start()
+	dispatcher.dispatch(nats.NewMsg("2"))
+	if !dispatcher.drain(time.Second) {
+		t.Fatalf("expected the dispatcher to drain")
+	}
+	if len(handled) != 1 {
+		t.Fatalf("expected the in-flight message to be handled before the drain returned")
+	}
+}
+
+func TestRedeliveryDelay(t *testing.T) {
+	backoff := []time.Duration{time.Second, time.Second * 5}
+	if redeliveryDelay(nil, 3) != 0 {
+		t.Fatalf("expected no delay without a backoff")
+	}
+	if redeliveryDelay(backoff, 1) != time.Second || redeliveryDelay(backoff, 2) != time.Second*5 {
+		t.Fatalf("expected the delay to follow the backoff")
+	}
+	if redeliveryDelay(backoff, 10) != time.Second*5 {
+		t.Fatalf("expected the delay to be capped at the last backoff")
+	}
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	stream := &Stream[*wrapperspb.StringValue]{window: 2, seq: 2, credit: make(chan struct{}, 1)}
+	stream.ctx, stream.cancel = context.WithTimeout(context.Background(), time.Second)
+	defer stream.cancel()
+	waited := make(chan error, 1)
+	go func() {
+		waited <- stream.wait()
 	}()
-	<-time.After(time.Second * 5)
-	i = 10
-	<-time.After(time.Second * 5)
+	select {
+	case <-waited:
+		t.Fatalf("expected the stream to wait for an acknowledgement")
+	case <-time.After(time.Millisecond * 50):
+	}
+	ack := nats.NewMsg("ack")
+	ack.Header.Set(STREAM_SEQ_HEADER, "1")
+	stream.onAck(ack)
+	if err := <-waited; err != nil {
+		t.Fatal(err)
+	}
+	cancel := nats.NewMsg("ack")
+	cancel.Header.Set(STREAM_CANCEL_HEADER, "true")
+	stream.onAck(cancel)
+	if stream.wait() == nil {
+		t.Fatalf("expected a cancelled stream to stop sending")
+	}
+}
+
+func TestStreamOverJetStream(t *testing.T) {
+	service := NewStream("test", "test.stream", "test", func(ctx context.Context, request *wrapperspb.StringValue, stream *Stream[*wrapperspb.StringValue]) error {
+		return nil
+	}, WithJetStream("test", "test", PULL))
+	if service.Start() == nil {
+		t.Fatalf("expected a streaming service over JetStream to be rejected")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	trace := make([]string, 0)
+	tracer := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, request proto.Message) (proto.Message, error) {
+				trace = append(trace, name)
+				return next(ctx, request)
+			}
+		}
+	}
+	tenant := func(next Handler) Handler {
+		return func(ctx context.Context, request proto.Message) (proto.Message, error) {
+			if HeaderFromContext(ctx, "tenant") == "" {
+				return nil, fmt.Errorf("missing tenant")
+			}
+			SetResponseHeader(ctx, "audited", "true")
+			request.(*wrapperspb.StringValue).Value += "!"
+			return next(ctx, request)
+		}
+	}
+	service := New[*wrapperspb.StringValue, *wrapperspb.StringValue]("nats", "test", "test", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String(request.Value), nil
+	}, WithMiddleware(tracer("first"), tracer("second"), tenant))
+	msg := nats.NewMsg("test")
+	ctx, cancel := newContext(context.Background(), msg, insight.New("test", "test"))
+	defer cancel()
+	if _, err := service.invoke(ctx, wrapperspb.String("goal")); err == nil {
+		t.Fatalf("expected the tenant middleware to reject the request")
+	}
+	msg.Header.Set("tenant", "goal")
+	response, err := service.invoke(ctx, wrapperspb.String("goal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message, _ := MessageFromContext(ctx)
+	if response.Value != "goal!" || message.ResponseHeader.Get("audited") != "true" {
+		t.Fatalf("unexpected response %s", response.Value)
+	}
+	if fmt.Sprint(trace) != "[first second first second]" {
+		t.Fatalf("unexpected order %v", trace)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	service := New[*wrapperspb.StringValue, *wrapperspb.StringValue]("nats", "test", "test", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	}, WithCache(time.Minute, WithCacheKey(KeyFromHeaders("tenant"), KeyFromFields("value"))))
+	msg := nats.NewMsg("test")
+	msg.Header.Set("tenant", "goal")
+	ctx, cancel := newContext(context.Background(), msg, insight.New("test", "test"))
+	defer cancel()
+	key, err := service.cacheKey(ctx, wrapperspb.String("a b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != CacheKey("goal", "a b") || !strings.HasPrefix(key, "goal.") || strings.Contains(key, " ") {
+		t.Fatalf("unexpected key %s", key)
+	}
+	if _, err := KeyFromFields("missing")(ctx, wrapperspb.String("")); err == nil {
+		t.Fatalf("expected an unknown field to be rejected")
+	}
+}
+
+func TestLocalCache(t *testing.T) {
+	cache, err := LocalBackend()(nil, "local", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := New[*wrapperspb.StringValue, *wrapperspb.StringValue]("nats", "local", "test", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	}, WithCache(time.Minute, WithCacheBackend(LocalBackend())))
+	service.cache = cache
+	for _, key := range []string{"a.1", "a.2", "b.1"} {
+		if err := service.store(key, &cacheEntry{Data: []byte(key)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entry, state := service.lookup("a.1"); state != FRESH || string(entry.Data) != "a.1" {
+		t.Fatalf("expected a fresh entry")
+	}
+	if err := service.InvalidatePrefix("a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, state := service.lookup("a.2"); state != MISS {
+		t.Fatalf("expected the prefix to be invalidated")
+	}
+	if _, state := service.lookup("b.1"); state != FRESH {
+		t.Fatalf("expected other keys to be kept")
+	}
+}
+
+func TestIdempotency(t *testing.T) {
+	key := nuid.Next()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	service := New[*wrapperspb.StringValue, *wrapperspb.StringValue]("nats", "idempotent", "test", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		calls.Add(1)
+		<-release
+		return wrapperspb.String(request.Value + "!"), nil
+	}, WithIdempotency(time.Minute, LocalBackend()))
+	err := service.configureIdempotency()
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.ctx = context.Background()
+	data, err := service.codec.Encode("idempotent", wrapperspb.String("goal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle := func() *wrapperspb.StringValue {
+		msg := nats.NewMsg("idempotent")
+		msg.Data = data
+		msg.Header.Set(IDEMPOTENCY_KEY_HEADER, key)
+		ctx := service.handle(msg, internal.NewEventCtx)
+		if ctx.Status() != "SUCCESS" {
+			t.Errorf("unexpected status %s", ctx.Status())
+			return nil
+		}
+		response := &wrapperspb.StringValue{}
+		if err := service.codec.Decode("idempotent", ctx.Data(), response); err != nil {
+			t.Error(err)
+		}
+		return response
+	}
+	responses := make(chan *wrapperspb.StringValue, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			responses <- handle()
+		}()
+	}
+	<-time.After(time.Millisecond * 50)
+	close(release)
+	for i := 0; i < 2; i++ {
+		if response := <-responses; response == nil || response.Value != "goal!" {
+			t.Fatalf("unexpected response %v", response)
+		}
+	}
+	if response := handle(); response == nil || response.Value != "goal!" || calls.Load() != 1 {
+		t.Fatalf("expected duplicates to replay the first execution but the handler ran %d times", calls.Load())
+	}
+	if New[*wrapperspb.StringValue, *wrapperspb.StringValue]("nats", "idempotent", "test", func(request *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+		return request, nil
+	}, WithIdempotency(0, LocalBackend())).configureIdempotency() == nil {
+		t.Fatalf("expected a ttl of zero to be rejected")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	RegisterSchemaVersion[*wrapperspb.StringValue]("2")
+	RegisterAdapter("1", func(old *wrapperspb.Int64Value) (*wrapperspb.StringValue, error) {
+		return wrapperspb.String(fmt.Sprint(old.Value)), nil
+	})
+	codec := &codecs.CompressedProtoConn{}
+	encode := func(message proto.Message, version string) *nats.Msg {
+		msg := nats.NewMsg("test")
+		data, err := codec.Encode("test", message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg.Data = data
+		StampSchema(msg.Header, message)
+		if version != "" {
+			msg.Header.Set(SCHEMA_VERSION_HEADER, version)
+		}
+		return msg
+	}
+	current := encode(wrapperspb.String("goal"), "")
+	if current.Header.Get(SCHEMA_VERSION_HEADER) != "2" || current.Header.Get(SCHEMA_TYPE_HEADER) != "google.protobuf.StringValue" {
+		t.Fatalf("unexpected schema headers %v", current.Header)
+	}
+	target := &wrapperspb.StringValue{}
+	if err := DecodeWithSchema(codec, current, target); err != nil || target.Value != "goal" {
+		t.Fatalf("unexpected value %v %v", target, err)
+	}
+	if err := DecodeWithSchema(codec, encode(wrapperspb.Int64(42), "1"), target); err != nil || target.Value != "42" {
+		t.Fatalf("expected the adapter to convert the old version but got %v %v", target, err)
+	}
+	status, err := decodeStatusOf(DecodeWithSchema(codec, encode(wrapperspb.String("goal"), "1"), target))
+	if status != "FAIL:SCHEMA" || err == nil {
+		t.Fatalf("expected an unknown version to fail with FAIL:SCHEMA but got %s", status)
+	}
 }
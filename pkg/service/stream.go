@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+	"github.com/vedadiyan/goal/pkg/wire"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	STREAM_SEQ_HEADER    = wire.STREAM_SEQ_HEADER
+	STREAM_EOS_HEADER    = wire.STREAM_EOS_HEADER
+	STREAM_ACK_HEADER    = wire.STREAM_ACK_HEADER
+	STREAM_WINDOW_HEADER = wire.STREAM_WINDOW_HEADER
+	STREAM_CANCEL_HEADER = wire.STREAM_CANCEL_HEADER
+	STREAM_WINDOW        = wire.STREAM_WINDOW
+)
+
+type StreamHandler[TReq proto.Message, TRes proto.Message] func(ctx context.Context, request TReq, stream *Stream[TRes]) error
+
+// Stream sends the chunks of a streaming response to the reply inbox of the request.
+// At most window chunks are sent ahead of the acknowledgements of the client, after
+// which Send blocks until the client catches up or the request context is done.
+type Stream[TRes proto.Message] struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	conn    *nats.Conn
+	codec   *codecs.CompressedProtoConn
+	subject string
+	ack     string
+	window  uint64
+	seq     uint64
+	acked   atomic.Uint64
+	credit  chan struct{}
+	subs    *nats.Subscription
+}
+
+func newStream[TRes proto.Message](ctx context.Context, conn *nats.Conn, codec *codecs.CompressedProtoConn, msg *nats.Msg) (*Stream[TRes], error) {
+	window, err := strconv.ParseUint(msg.Header.Get(STREAM_WINDOW_HEADER), 10, 64)
+	if err != nil || window == 0 {
+		window = STREAM_WINDOW
+	}
+	stream := &Stream[TRes]{
+		conn:    conn,
+		codec:   codec,
+		subject: msg.Reply,
+		ack:     nats.NewInbox(),
+		window:  window,
+		credit:  make(chan struct{}, 1),
+	}
+	stream.ctx, stream.cancel = context.WithCancel(ctx)
+	stream.subs, err = conn.Subscribe(stream.ack, stream.onAck)
+	if err != nil {
+		stream.cancel()
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (s *Stream[TRes]) close() error {
+	defer s.cancel()
+	return s.subs.Unsubscribe()
+}
+
+func (s *Stream[TRes]) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Stream[TRes]) Send(response TRes) error {
+	err := s.wait()
+	if err != nil {
+		return err
+	}
+	bytes, err := s.codec.Encode(s.subject, response)
+	if err != nil {
+		return err
+	}
+	s.seq++
+	msg := nats.NewMsg(s.subject)
+	msg.Data = bytes
+	msg.Header.Set("status", "SUCCESS")
+	msg.Header.Set(STREAM_SEQ_HEADER, strconv.FormatUint(s.seq, 10))
+	msg.Header.Set(STREAM_ACK_HEADER, s.ack)
+	StampSchema(msg.Header, response)
+	return s.conn.PublishMsg(msg)
+}
+
+func (s *Stream[TRes]) wait() error {
+	for s.seq-s.acked.Load() >= s.window {
+		select {
+		case <-s.credit:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	return s.ctx.Err()
+}
+
+func (s *Stream[TRes]) onAck(msg *nats.Msg) {
+	if msg.Header.Get(STREAM_CANCEL_HEADER) != "" {
+		s.cancel()
+		return
+	}
+	seq, err := strconv.ParseUint(msg.Header.Get(STREAM_SEQ_HEADER), 10, 64)
+	if err != nil {
+		return
+	}
+	for {
+		acked := s.acked.Load()
+		if seq <= acked || s.acked.CompareAndSwap(acked, seq) {
+			break
+		}
+	}
+	select {
+	case s.credit <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Stream[TRes]) eos() internal.Header {
+	return internal.Header{
+		STREAM_EOS_HEADER: "true",
+		STREAM_SEQ_HEADER: strconv.FormatUint(s.seq+1, 10),
+	}
+}
+
+func (t NATSService[TReq, TRes, TFuncType]) stream(ctx *internal.NatsCtx, msg *nats.Msg, request TReq) {
+	insight := ctx.Insight()
+	stream, err := newStream[TRes](ctx.Context(), t.conn, t.codec, msg)
+	if err != nil {
+		insight.Error(err)
+		ctx.Error(internal.Header{"status": "FAIL:STREAM"})
+		return
+	}
+	defer func() {
+		err := stream.close()
+		if err != nil {
+			insight.Warn(err)
+		}
+	}()
+	err = t.invokeStream(stream.Context(), request, stream)
+	headers := stream.eos()
+	if err != nil {
+		insight.Error(err)
+		headers["status"] = "FAIL:HANDLE"
+		ctx.Error(headers, err)
+		return
+	}
+	headers["status"] = "SUCCESS"
+	ctx.Success(nil, headers)
+}
+
+func NewStream[TReq proto.Message, TRes proto.Message](connName string, namespace string, queue string, handlerFn StreamHandler[TReq, TRes], options ...Option) *NATSService[TReq, TRes, StreamHandler[TReq, TRes]] {
+	service := newService[TReq, TRes, StreamHandler[TReq, TRes]](connName, namespace, queue, options...)
+	service.streamFn = handlerFn
+	return service
+}
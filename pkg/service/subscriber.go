@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/nats-io/nats.go"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
+	"github.com/vedadiyan/goal/pkg/di"
+	"github.com/vedadiyan/goal/pkg/fault"
+	"github.com/vedadiyan/goal/pkg/health"
+	"github.com/vedadiyan/goal/pkg/insight"
+	internal "github.com/vedadiyan/goal/pkg/service/internal"
+	"google.golang.org/protobuf/proto"
+)
+
+type EventHandler[TEvent proto.Message] func(ctx context.Context, event TEvent) error
+
+// NATSSubscriber consumes published events without replying to them. Every instance
+// receives every event unless a queue group is given, in which case each event is
+// delivered to one member of the group. Failures are reported to insight and to the
+// subjects given with WithOnFailureCallBacks.
+type NATSSubscriber[TEvent proto.Message] struct {
+	ctx          context.Context
+	cancel       context.CancelFunc
+	conn         *nats.Conn
+	codec        *codecs.CompressedProtoConn
+	reloadState  chan ReloadStates
+	subscription *nats.Subscription
+	dispatcher   *dispatcher
+	discovery    *discovery
+	connName     string
+	subject      string
+	queue        string
+	handlerFn    EventHandler[TEvent]
+	options      NATSServiceOptions
+	newEvent     func() TEvent
+}
+
+func (t *NATSSubscriber[TEvent]) Configure(b bool) {
+	if !b {
+		di.OnRefreshWithName(t.connName, func(e di.Events) {
+			t.reloadState <- RELOADING
+			if READY == <-t.reloadState {
+				t.reloadState <- RELOADED
+				return
+			}
+		})
+	}
+	t.conn = di.ResolveWithNameOrPanic[nats.Conn](t.connName, nil)
+}
+
+func (t *NATSSubscriber[TEvent]) Start() error {
+	err := validateDispatcher(t.subject, t.options)
+	if err != nil {
+		return err
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	t.dispatcher = newDispatcher(t.subject, t.options, t.handler, t.busy)
+	t.dispatcher.start()
+	var subs *nats.Subscription
+	if t.queue == "" {
+		subs, err = t.conn.Subscribe(t.subject, t.dispatcher.dispatch)
+	} else {
+		subs, err = t.conn.QueueSubscribe(t.subject, t.queue, t.dispatcher.dispatch)
+	}
+	if err != nil {
+		t.dispatcher.stop()
+		t.cancel()
+		return err
+	}
+	t.subscription = subs
+	t.discovery = newDiscovery(t.subject, t.options, map[string]string{
+		"queue":      t.queue,
+		"event_type": fullNameOf(t.newEvent()),
+	})
+	return t.discovery.start(t.conn)
+}
+
+func (t NATSSubscriber[TEvent]) Shutdown() error {
+	if t.cancel != nil {
+		defer t.cancel()
+	}
+	var err error
+	if t.discovery != nil {
+		t.discovery.stop()
+	}
+	if t.subscription != nil && !t.conn.IsDraining() && !t.conn.IsClosed() {
+		err = t.subscription.Unsubscribe()
+	}
+	if t.dispatcher != nil && !t.dispatcher.drain(t.options.gracePeriod) {
+		insight.New(t.subject, "shutdown").Warn(gracePeriodExceededError(t.subject, t.options.gracePeriod).Error())
+	}
+	return err
+}
+
+func (t NATSSubscriber[TEvent]) Reload() chan ReloadStates {
+	return t.reloadState
+}
+
+func (t *NATSSubscriber[TEvent]) Checks() map[string]health.Check {
+	return map[string]health.Check{
+		"nats." + t.subject: connCheck(func() *nats.Conn {
+			return t.conn
+		}),
+	}
+}
+
+func (t NATSSubscriber[TEvent]) handler(msg *nats.Msg) {
+	insight := insight.New(t.subject, msg.Subject)
+	defer insight.Close()
+	msgCtx, cancel := newContext(t.ctx, msg, insight)
+	defer cancel()
+	scope := di.NewScope(msgCtx)
+	defer scope.Close()
+	ctx := internal.NewEventCtx(scope.Context(), t.conn, insight, msg, t.options.onerror, nil)
+	event := t.newEvent()
+	insight.OnFailure(func(err error) {
+		ctx.Error(internal.Header{"status": "FAIL:RECOVERED"}, err)
+	})
+	if len(msg.Data) > 0 {
+		err := DecodeWithSchema(t.codec, msg, event)
+		if err != nil {
+			insight.Error(err)
+			status, err := decodeStatusOf(err)
+			ctx.Error(internal.Header{"status": status}, err)
+			return
+		}
+	}
+	insight.Start(event)
+	handler := chain(func(ctx context.Context, request proto.Message) (proto.Message, error) {
+		event, ok := request.(TEvent)
+		if !ok {
+			return nil, invalidMessageError(request)
+		}
+		return nil, t.handlerFn(ctx, event)
+	}, t.options.middleware)
+	_, err := handler(ctx.Context(), event)
+	if err != nil {
+		insight.Error(err)
+		ctx.Error(internal.Header{"status": "FAIL:HANDLE"}, err)
+	}
+}
+
+func (t NATSSubscriber[TEvent]) busy(msg *nats.Msg) {
+	insight := insight.New(t.subject, msg.Subject)
+	err := fault.New(fault.RESOURCE_EXHAUSTED, "the subscriber is busy").WithRetryable(true)
+	insight.Error(err)
+	ctx := internal.NewEventCtx(t.ctx, t.conn, insight, msg, t.options.onerror, nil)
+	ctx.Error(internal.Header{"status": "FAIL:BUSY"}, err)
+}
+
+func NewSubscriber[TEvent proto.Message](connName string, subject string, queue string, handlerFn EventHandler[TEvent], options ...Option) *NATSSubscriber[TEvent] {
+	tEvent := reflect.TypeOf(*new(TEvent)).Elem()
+	subscriber := NATSSubscriber[TEvent]{
+		subject:     subject,
+		queue:       queue,
+		handlerFn:   handlerFn,
+		connName:    connName,
+		reloadState: make(chan ReloadStates),
+		newEvent: func() TEvent {
+			return reflect.New(tEvent).Interface().(TEvent)
+		},
+		codec: &codecs.CompressedProtoConn{},
+	}
+	subscriber.options.gracePeriod = _gracePeriod
+	for _, option := range options {
+		option(&subscriber.options)
+	}
+	return &subscriber
+}
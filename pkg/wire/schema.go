@@ -0,0 +1,123 @@
+package wire
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	codecs "github.com/vedadiyan/goal/pkg/bus/nats"
+	"github.com/vedadiyan/goal/pkg/fault"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	SCHEMA_TYPE_HEADER    = "schema-type"
+	SCHEMA_VERSION_HEADER = "schema-version"
+	ENCODING_HEADER       = "encoding"
+	ENCODING              = "protobuf+zstd"
+)
+
+type adapter struct {
+	new   func() proto.Message
+	adapt func(old proto.Message) (proto.Message, error)
+}
+
+var _schemaVersions sync.Map
+var _adapters sync.Map
+
+// RegisterSchemaVersion sets the version that is stamped on messages of type T and
+// that messages of type T are expected to carry when they are received.
+func RegisterSchemaVersion[T proto.Message](version string) {
+	_schemaVersions.Store(FullNameOf(*new(T)), version)
+}
+
+// RegisterAdapter converts messages of type TOld stamped with the given version to
+// TNew when a TNew is expected. TOld and TNew may be the same type when only the
+// schema version differs.
+func RegisterAdapter[TOld proto.Message, TNew proto.Message](version string, adapt func(old TOld) (TNew, error)) {
+	old := *new(TOld)
+	_adapters.Store(adapterKey(FullNameOf(old), version), adapter{
+		new: func() proto.Message {
+			return old.ProtoReflect().New().Interface()
+		},
+		adapt: func(old proto.Message) (proto.Message, error) {
+			return adapt(old.(TOld))
+		},
+	})
+}
+
+func StampSchema(header nats.Header, message proto.Message) {
+	for key, value := range SchemaOf(message) {
+		header.Set(key, value)
+	}
+}
+
+// DecodeWithSchema decodes the message into target after checking its schema headers.
+// Messages without schema headers are decoded as they are, messages of another type
+// or version are converted with a registered adapter and the remaining ones are
+// rejected.
+func DecodeWithSchema(codec *codecs.CompressedProtoConn, msg *nats.Msg, target proto.Message) error {
+	fullName := msg.Header.Get(SCHEMA_TYPE_HEADER)
+	if fullName == "" {
+		return codec.Decode(msg.Subject, msg.Data, target)
+	}
+	if encoding := msg.Header.Get(ENCODING_HEADER); encoding != "" && encoding != ENCODING {
+		return schemaError(fmt.Sprintf("the encoding `%s` is not supported", encoding))
+	}
+	version := msg.Header.Get(SCHEMA_VERSION_HEADER)
+	if fullName == FullNameOf(target) && version == versionOf(fullName) {
+		return codec.Decode(msg.Subject, msg.Data, target)
+	}
+	value, ok := _adapters.Load(adapterKey(fullName, version))
+	if !ok {
+		return schemaError(fmt.Sprintf("expected `%s` version `%s` but received `%s` version `%s`", FullNameOf(target), versionOf(FullNameOf(target)), fullName, version))
+	}
+	adapter := value.(adapter)
+	old := adapter.new()
+	err := codec.Decode(msg.Subject, msg.Data, old)
+	if err != nil {
+		return err
+	}
+	adapted, err := adapter.adapt(old)
+	if err != nil {
+		return schemaError(err.Error())
+	}
+	if FullNameOf(adapted) != FullNameOf(target) {
+		return schemaError(fmt.Sprintf("the adapter for `%s` version `%s` returned `%s`", fullName, version, FullNameOf(adapted)))
+	}
+	proto.Reset(target)
+	proto.Merge(target, adapted)
+	return nil
+}
+
+func SchemaOf(message proto.Message) map[string]string {
+	fullName := FullNameOf(message)
+	header := map[string]string{
+		SCHEMA_TYPE_HEADER: fullName,
+		ENCODING_HEADER:    ENCODING,
+	}
+	if version := versionOf(fullName); version != "" {
+		header[SCHEMA_VERSION_HEADER] = version
+	}
+	return header
+}
+
+func FullNameOf(message proto.Message) string {
+	return string(message.ProtoReflect().Descriptor().FullName())
+}
+
+func schemaError(message string) error {
+	return fault.New(fault.INVALID_ARGUMENT, message)
+}
+
+func versionOf(fullName string) string {
+	version, ok := _schemaVersions.Load(fullName)
+	if !ok {
+		return ""
+	}
+	return version.(string)
+}
+
+func adapterKey(fullName string, version string) string {
+	return fmt.Sprintf("%s@%s", fullName, version)
+}
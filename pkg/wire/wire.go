@@ -0,0 +1,14 @@
+package wire
+
+const (
+	TIMEOUT_HEADER = "timeout"
+)
+
+const (
+	STREAM_SEQ_HEADER    = "seq"
+	STREAM_EOS_HEADER    = "eos"
+	STREAM_ACK_HEADER    = "ack"
+	STREAM_WINDOW_HEADER = "window"
+	STREAM_CANCEL_HEADER = "cancel"
+	STREAM_WINDOW        = 16
+)